@@ -0,0 +1,299 @@
+package model
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"strings"
+	"text/template"
+)
+
+type AugmentStrategy int
+
+const (
+	// AugmentParaphrase adds LLM paraphrases of the query.
+	AugmentParaphrase AugmentStrategy = iota
+	// AugmentHyDE adds a hypothetical answer passage written by the LLM.
+	AugmentHyDE
+	// AugmentParaphraseAndHyDE adds both.
+	AugmentParaphraseAndHyDE
+)
+
+// DefaultHyDEPrompt is the text/template used to ask for a hypothetical
+// passage. It receives the query and the word limit.
+const DefaultHyDEPrompt = `Write a short passage that answers the question below, as it could appear in a document.
+Use at most {{.MaxWords}} words and output only the passage.
+Question: {{.Query}}
+Passage:`
+
+// HyDEOptions configures hypothetical document augmentation.
+type HyDEOptions struct {
+	Prompt   string  // text/template with .Query and .MaxWords, defaults to the HyDE prompt of the index template
+	MaxWords int     // The passage is cut to this many words, defaults to 100
+	Weight   float64 // Weight of the passage, defaults to the AugmentOptions weight
+}
+
+func (opts HyDEOptions) withDefaults() HyDEOptions {
+	if opts.Prompt == "" {
+		opts.Prompt = DefaultHyDEPrompt
+	}
+	if opts.MaxWords <= 0 {
+		opts.MaxWords = 100
+	}
+	return opts
+}
+
+// GenerateHypotheticalDocument asks the LLM for a passage answering query,
+// in the spirit of HyDE (Gao et al., 2022).
+func GenerateHypotheticalDocument(query string, opts HyDEOptions) (string, error) {
+	opts = opts.withDefaults()
+	prompt, err := opts.prompt(query)
+	if err != nil {
+		return "", err
+	}
+
+	client := NewLLMClient(ClientConfig{
+		Provider:       "openai",
+		DeploymentName: "gpt-4o-mini",
+	})
+	passage, err := client.CompletionText(context.Background(), ChatCompletionRequest{
+		Models: []string{"gpt-4o-mini"},
+		Messages: []ConvMessage{
+			{Role: "user", Content: prompt},
+		},
+		Temperature: 0.7,
+		MaxTokens:   opts.MaxWords * 2,
+	})
+	if err != nil {
+		return "", err
+	}
+	return firstWords(passage, opts.MaxWords), nil
+}
+
+// prompt renders the HyDE prompt for query.
+func (opts HyDEOptions) prompt(query string) (string, error) {
+	tmpl, err := template.New("hyde").Parse(opts.Prompt)
+	if err != nil {
+		return "", err
+	}
+	var prompt bytes.Buffer
+	err = tmpl.Execute(&prompt, struct {
+		Query    string
+		MaxWords int
+	}{query, opts.MaxWords})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(prompt.String()), nil
+}
+
+// firstWords returns the first n words of text, separated by single spaces.
+func firstWords(text string, n int) string {
+	words := strings.Fields(text)
+	return strings.Join(words[:min(len(words), n)], " ")
+}
+
+// WeightedQuery is an augmented query with its own weight.
+type WeightedQuery struct {
+	Text   string
+	Weight float64
+	Tokens []string // Pre-analysed tokens, Text is processed when nil
+}
+
+// Augment adds weighted augmented queries to the query, before Initialize.
+func (query *Query) Augment(augmentations ...WeightedQuery) {
+	for _, augmentation := range augmentations {
+		for len(query.AugmentedTokens) < len(query.AugmentedQueries) {
+			query.AugmentedTokens = append(query.AugmentedTokens, nil)
+		}
+		query.AugmentedQueries = append(query.AugmentedQueries, augmentation.Text)
+		query.AugmentedWeights = append(query.AugmentedWeights, augmentation.Weight)
+		query.AugmentedTokens = append(query.AugmentedTokens, augmentation.Tokens)
+	}
+}
+
+// GenerateWeightedAugmentedQueries is GenerateAugmentedQueries with a
+// confidence in [0, 1] returned by the LLM for each augmented query, as its
+// weight.
+func GenerateWeightedAugmentedQueries(query string, num_augmented_queries int) ([]WeightedQuery, error) {
+	return GenerateWeightedAugmentedQueriesWithTemplate(query, num_augmented_queries, GetPromptTemplate("english"))
+}
+
+// GenerateWeightedAugmentedQueriesWithTemplate generates weighted augmented
+// queries with the given prompt template.
+func GenerateWeightedAugmentedQueriesWithTemplate(query string, num_augmented_queries int, tmpl PromptTemplate) ([]WeightedQuery, error) {
+	messages, err := tmpl.WeightedMessages(query, num_augmented_queries)
+	if err != nil {
+		return nil, err
+	}
+	client := NewLLMClient(ClientConfig{
+		Provider:       "openai",
+		DeploymentName: "gpt-4o-mini",
+	})
+	response, err := client.CompletionText(context.Background(), ChatCompletionRequest{
+		Models:      []string{"gpt-4o-mini"},
+		Messages:    messages,
+		Temperature: 0.7,
+		MaxTokens:   300,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parseWeightedQueries(response, num_augmented_queries)
+}
+
+func parseWeightedQueries(response string, n int) ([]WeightedQuery, error) {
+	start, end := strings.Index(response, "{"), strings.LastIndex(response, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("no JSON in LLM response: %q", response)
+	}
+	var result struct {
+		AugmentedQueries []struct {
+			Query      string   `json:"query"`
+			Confidence *float64 `json:"confidence"`
+		} `json:"augmented queries"`
+	}
+	if err := json.Unmarshal([]byte(response[start:end+1]), &result); err != nil {
+		return nil, fmt.Errorf("error parsing LLM response: %v", err)
+	}
+	queries := []WeightedQuery{}
+	for _, augmented := range result.AugmentedQueries {
+		if len(queries) == n {
+			break
+		}
+		if strings.TrimSpace(augmented.Query) == "" {
+			continue
+		}
+		confidence := 1.0
+		if augmented.Confidence != nil {
+			confidence = min(max(*augmented.Confidence, 0), 1)
+		}
+		queries = append(queries, WeightedQuery{Text: augmented.Query, Weight: confidence})
+	}
+	return queries, nil
+}
+
+// AugmentOptions configures how augmented queries are generated and combined.
+type AugmentOptions struct {
+	Strategy   AugmentStrategy
+	NumQueries int     // Number of paraphrases to generate
+	Weight     float64 // Weight of each augmented query
+	// OriginalWeight is the weight of each token of the original query, or
+	// of its ranking when Separate is set, defaults to 1.
+	OriginalWeight float64
+	// UseConfidence scales the weight of each paraphrase by the confidence
+	// the LLM gives it.
+	UseConfidence bool
+	HyDE          HyDEOptions
+	// Separate runs the original and each augmented query on their own and
+	// combines the rankings with Fusion instead of merging all their tokens
+	// into a single weighted query. The fused score is reported as both the
+	// raw and the BMX-normalised score.
+	Separate bool
+	Fusion   FusionMethod
+	RRFK     float64 // RRF constant, defaults to 60
+	// CandidateK is the number of results kept per query when Separate is
+	// set, defaults to max(50, Offset+TopK).
+	CandidateK int
+}
+
+// hydeWeight returns the weight of the hypothetical passage.
+func (aug AugmentOptions) hydeWeight() float64 {
+	if aug.HyDE.Weight > 0 {
+		return aug.HyDE.Weight
+	}
+	return aug.Weight
+}
+
+// SearchAugmentedWithOptions generates augmented queries for query and
+// searches with them according to aug.
+func (adapter *BMXAdapter) SearchAugmentedWithOptions(query string, aug AugmentOptions, opts SearchOptions) (SearchResults, error) {
+	var augmentations []WeightedQuery
+	if aug.Strategy != AugmentHyDE {
+		if aug.UseConfidence {
+			paraphrases, err := adapter.AugmentQueryWeighted(query, aug.NumQueries)
+			if err != nil {
+				return SearchResults{}, err
+			}
+			for _, paraphrase := range paraphrases {
+				paraphrase.Weight *= aug.Weight
+				augmentations = append(augmentations, paraphrase)
+			}
+		} else {
+			paraphrases, err := adapter.AugmentQuery(query, aug.NumQueries)
+			if err != nil {
+				return SearchResults{}, err
+			}
+			for _, paraphrase := range paraphrases {
+				augmentations = append(augmentations, WeightedQuery{Text: paraphrase, Weight: aug.Weight})
+			}
+		}
+	}
+	if aug.Strategy != AugmentParaphrase {
+		passage, err := adapter.HypotheticalDocument(query, aug.HyDE)
+		if err != nil {
+			return SearchResults{}, err
+		}
+		augmentations = append(augmentations, WeightedQuery{Text: passage, Weight: aug.hydeWeight()})
+	}
+	return adapter.SearchWithAugmentations(query, augmentations, aug, opts), nil
+}
+
+// SearchWithAugmentations searches with caller-supplied augmented queries,
+// without any LLM call. Each augmentation keeps its own weight; only the
+// original weight and fusion settings of aug are used.
+func (adapter *BMXAdapter) SearchWithAugmentations(query string, augmentations []WeightedQuery, aug AugmentOptions, opts SearchOptions) SearchResults {
+	if !aug.Separate {
+		q := opts.query(query)
+		q.OriginalWeight = aug.OriginalWeight
+		q.Augment(augmentations...)
+		q.Initialize(adapter.bmx)
+		results := topResults(&q, adapter.bmx, opts)
+		results.Facets = q.Facets(adapter.bmx, opts)
+		return results
+	}
+
+	candidateK := aug.CandidateK
+	if candidateK <= 0 {
+		candidateK = max(50, opts.Offset+opts.TopK)
+	}
+	original := opts.query(query)
+	original.Initialize(adapter.bmx)
+	lists := []SearchResults{topResults(&original, adapter.bmx, SearchOptions{TopK: candidateK, Normalization: NormalizeRaw, MinScore: 1e-12})}
+	originalWeight := aug.OriginalWeight
+	if originalWeight <= 0 {
+		originalWeight = 1
+	}
+	weights := []float64{originalWeight}
+	// Matching and highlighting use the tokens of every query.
+	tokens := maps.Clone(original.Tokens)
+	for _, augmentation := range augmentations {
+		q := opts.query(augmentation.Text)
+		if augmentation.Tokens != nil {
+			q = opts.query("")
+			q.Augment(WeightedQuery{Text: augmentation.Text, Weight: 1, Tokens: augmentation.Tokens})
+		}
+		// Augmented queries are analysed in the language of the original one.
+		q.language = original.language
+		q.Initialize(adapter.bmx)
+		lists = append(lists, topResults(&q, adapter.bmx, SearchOptions{TopK: candidateK, Normalization: NormalizeRaw, MinScore: 1e-12}))
+		weights = append(weights, augmentation.Weight)
+		for token, weight := range q.Tokens {
+			if _, ok := tokens[token]; !ok && weight > 0 {
+				tokens[token] = augmentation.Weight * weight
+			}
+		}
+	}
+
+	// The fused scores are ranked and paged like the scores of a single
+	// query, and reported as both its raw and BMX-normalised scores.
+	fused := original
+	fused.Tokens = tokens
+	fused.ScoreTable = fusedScores(lists, FuseOptions{Method: aug.Fusion, Weights: weights, RRFK: aug.RRFK})
+	fused.NormalizedScoreTable = fused.ScoreTable
+	results := topResults(&fused, adapter.bmx, opts)
+	results.Facets = fused.Facets(adapter.bmx, opts)
+	return results
+}
@@ -0,0 +1,135 @@
+package model
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHyDEPrompt(t *testing.T) {
+	tests := []struct {
+		name string
+		opts HyDEOptions
+		want []string // Substrings of the prompt
+	}{
+		{"default", HyDEOptions{}, []string{"at most 100 words", "Question: why is the sky blue"}},
+		{"max words", HyDEOptions{MaxWords: 20}, []string{"at most 20 words"}},
+		{"custom", HyDEOptions{Prompt: "Answer {{.Query}} in {{.MaxWords}} words"}, []string{"Answer why is the sky blue in 100 words"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prompt, err := tt.opts.withDefaults().prompt("why is the sky blue")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(prompt, want) {
+					t.Errorf("prompt %q does not contain %q", prompt, want)
+				}
+			}
+		})
+	}
+	for _, prompt := range []string{"{{.Query", "{{.Unknown}}"} {
+		if _, err := (HyDEOptions{Prompt: prompt}).withDefaults().prompt("q"); err == nil {
+			t.Errorf("prompt %q rendered, want an error", prompt)
+		}
+	}
+}
+
+func TestFirstWords(t *testing.T) {
+	tests := []struct {
+		text string
+		n    int
+		want string
+	}{
+		{"  the sky\nis   blue ", 10, "the sky is blue"},
+		{"the sky is blue", 2, "the sky"},
+		{"", 3, ""},
+	}
+	for _, tt := range tests {
+		if got := firstWords(tt.text, tt.n); got != tt.want {
+			t.Errorf("firstWords(%q, %d) = %q, want %q", tt.text, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestHyDEWeight(t *testing.T) {
+	if got := (AugmentOptions{Weight: 0.5}).hydeWeight(); got != 0.5 {
+		t.Errorf("weight without HyDE weight = %g, want the augmentation weight 0.5", got)
+	}
+	if got := (AugmentOptions{Weight: 0.5, HyDE: HyDEOptions{Weight: 0.2}}).hydeWeight(); got != 0.2 {
+		t.Errorf("weight = %g, want the HyDE weight 0.2", got)
+	}
+}
+
+// TestSearchWithPassage checks that the tokens of a hypothetical passage
+// bring in the documents sharing its vocabulary.
+func TestSearchWithPassage(t *testing.T) {
+	adapter := newTestAdapter(t, testDocs...)
+	passage := WeightedQuery{Text: "Bears eat fish.", Weight: 0.3}
+	results := adapter.SearchWithAugmentations("brown", []WeightedQuery{passage}, AugmentOptions{}, SearchOptions{TopK: 10})
+	if results.TotalHits != 2 || results.Keys[0] != "d" {
+		t.Errorf("results = %q with %d hits, want d, matching the query and the passage, first of 2", results.Keys, results.TotalHits)
+	}
+}
+
+func TestParseWeightedQueries(t *testing.T) {
+	response := `Here you go: {"query": "q", "augmented queries": [
+		{"query": "first", "confidence": 0.8},
+		{"query": "  "},
+		{"query": "no confidence"},
+		{"query": "too sure", "confidence": 1.5},
+		{"query": "one too many", "confidence": 0.1}]}`
+	got, err := parseWeightedQueries(response, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []WeightedQuery{{Text: "first", Weight: 0.8}, {Text: "no confidence", Weight: 1}, {Text: "too sure", Weight: 1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseWeightedQueries = %+v, want %+v", got, want)
+	}
+	for _, response := range []string{"no json", `{"augmented queries": [1]}`} {
+		if _, err := parseWeightedQueries(response, 3); err == nil {
+			t.Errorf("parseWeightedQueries(%q) succeeded, want an error", response)
+		}
+	}
+}
+
+func TestAugmentWeights(t *testing.T) {
+	adapter := newTestAdapter(t, testDocs...)
+	q := Query{Text: "fox fox", OriginalWeight: 2}
+	q.Augment(WeightedQuery{Text: "red fox", Weight: 0.5}, WeightedQuery{Text: "ignored", Weight: 0.25, Tokens: []string{"hound"}})
+	q.Initialize(adapter.bmx)
+	want := map[string]float64{"fox": 4.5, "red": 0.5, "hound": 0.25}
+	if !reflect.DeepEqual(q.Tokens, want) {
+		t.Errorf("token weights = %v, want %v", q.Tokens, want)
+	}
+	if q.TotalWeight != 5.25 {
+		t.Errorf("TotalWeight = %g, want 5.25", q.TotalWeight)
+	}
+}
+
+// TestOriginalWeight checks that OriginalWeight trades the original query
+// against its augmentations, merged or fused.
+func TestOriginalWeight(t *testing.T) {
+	adapter := newTestAdapter(t, testDocs...)
+	augmentations := []WeightedQuery{{Text: "cat sleeps", Weight: 1}}
+	tests := []struct {
+		name string
+		aug  AugmentOptions
+		want string
+	}{
+		{"merged original", AugmentOptions{OriginalWeight: 10}, "b"},
+		{"merged augmentation", AugmentOptions{OriginalWeight: 0.05}, "c"},
+		{"fused original", AugmentOptions{Separate: true, Fusion: FusionWeighted, OriginalWeight: 10}, "b"},
+		{"fused augmentation", AugmentOptions{Separate: true, Fusion: FusionWeighted, OriginalWeight: 0.05}, "c"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := adapter.SearchWithAugmentations("quick red fox", augmentations, tt.aug, SearchOptions{TopK: 10})
+			if len(results.Keys) == 0 || results.Keys[0] != tt.want {
+				t.Errorf("results = %q, want %s first", results.Keys, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package model
+
+import "math/bits"
+
+// Bitmap is a set of document ordinals.
+type Bitmap struct {
+	words []uint64
+}
+
+func NewBitmap(size int) *Bitmap {
+	return &Bitmap{words: make([]uint64, (size+63)/64)}
+}
+
+// FullBitmap returns a bitmap containing the ordinals 0 to size-1.
+func FullBitmap(size int) *Bitmap {
+	b := NewBitmap(size)
+	for i := range b.words {
+		b.words[i] = ^uint64(0)
+	}
+	if rem := size % 64; rem != 0 {
+		b.words[len(b.words)-1] = (1 << rem) - 1
+	}
+	return b
+}
+
+func (b *Bitmap) grow(word int) {
+	if word >= len(b.words) {
+		b.words = append(b.words, make([]uint64, word-len(b.words)+1)...)
+	}
+}
+
+func (b *Bitmap) Add(i int) {
+	b.grow(i / 64)
+	b.words[i/64] |= 1 << (i % 64)
+}
+
+func (b *Bitmap) Contains(i int) bool {
+	if i < 0 || i/64 >= len(b.words) {
+		return false
+	}
+	return b.words[i/64]&(1<<(i%64)) != 0
+}
+
+func (b *Bitmap) Clone() *Bitmap {
+	return &Bitmap{words: append([]uint64(nil), b.words...)}
+}
+
+// And keeps the ordinals also in other.
+func (b *Bitmap) And(other *Bitmap) *Bitmap {
+	for i := range b.words {
+		if i < len(other.words) {
+			b.words[i] &= other.words[i]
+		} else {
+			b.words[i] = 0
+		}
+	}
+	return b
+}
+
+// Or adds the ordinals of other.
+func (b *Bitmap) Or(other *Bitmap) *Bitmap {
+	b.grow(len(other.words) - 1)
+	for i, word := range other.words {
+		b.words[i] |= word
+	}
+	return b
+}
+
+// AndNot removes the ordinals of other.
+func (b *Bitmap) AndNot(other *Bitmap) *Bitmap {
+	for i := range b.words {
+		if i < len(other.words) {
+			b.words[i] &^= other.words[i]
+		}
+	}
+	return b
+}
+
+func (b *Bitmap) Count() int {
+	count := 0
+	for _, word := range b.words {
+		count += bits.OnesCount64(word)
+	}
+	return count
+}
+
+// AndCount returns the size of the intersection without allocating it.
+func (b *Bitmap) AndCount(other *Bitmap) int {
+	count := 0
+	for i := 0; i < len(b.words) && i < len(other.words); i++ {
+		count += bits.OnesCount64(b.words[i] & other.words[i])
+	}
+	return count
+}
+
+// ForEach calls fn for each ordinal in increasing order.
+func (b *Bitmap) ForEach(fn func(i int)) {
+	for w, word := range b.words {
+		for word != 0 {
+			fn(w*64 + bits.TrailingZeros64(word))
+			word &= word - 1
+		}
+	}
+}
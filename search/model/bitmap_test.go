@@ -0,0 +1,61 @@
+package model
+
+import (
+	"slices"
+	"testing"
+)
+
+func bitmapOrdinals(b *Bitmap) []int {
+	ordinals := []int{}
+	b.ForEach(func(i int) { ordinals = append(ordinals, i) })
+	return ordinals
+}
+
+func bitmapOf(size int, ordinals ...int) *Bitmap {
+	b := NewBitmap(size)
+	for _, i := range ordinals {
+		b.Add(i)
+	}
+	return b
+}
+
+func TestBitmap(t *testing.T) {
+	a := bitmapOf(130, 0, 63, 64, 129)
+	b := bitmapOf(70, 1, 63, 64)
+	tests := []struct {
+		name string
+		got  *Bitmap
+		want []int
+	}{
+		{"full", FullBitmap(66), func() []int {
+			all := make([]int, 66)
+			for i := range all {
+				all[i] = i
+			}
+			return all
+		}()},
+		{"and", a.Clone().And(b), []int{63, 64}},
+		{"or", b.Clone().Or(a), []int{0, 1, 63, 64, 129}},
+		{"and not", a.Clone().AndNot(b), []int{0, 129}},
+		{"grows on add", bitmapOf(1, 200), []int{200}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bitmapOrdinals(tt.got); !slices.Equal(got, tt.want) {
+				t.Errorf("ordinals = %v, want %v", got, tt.want)
+			}
+			if tt.got.Count() != len(tt.want) {
+				t.Errorf("Count() = %d, want %d", tt.got.Count(), len(tt.want))
+			}
+		})
+	}
+	if got := a.AndCount(b); got != 2 {
+		t.Errorf("AndCount = %d, want 2", got)
+	}
+	if a.Contains(-1) || a.Contains(1000) || !a.Contains(129) {
+		t.Error("Contains out of range or of a member is wrong")
+	}
+	if got := bitmapOrdinals(a); !slices.Equal(got, []int{0, 63, 64, 129}) {
+		t.Errorf("operations on clones changed the original: %v", got)
+	}
+}
@@ -6,87 +6,96 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"sort"
 	"strings"
 	"sync"
 )
 
+// GenerateAugmentedQueries generates augmented queries with the default
+// English prompt template.
 func GenerateAugmentedQueries(query string, num_augmented_queries int) ([]string, error) {
-	prompt := fmt.Sprintf(`You are an intelligent query augmentation tool. Your task is to augment each
-query with {%d} similar queries and output JSONL format, like {”query”:
-”original query”, ”augmented queries”: [”augmented query 1”, ”augmented
-query 2”, ...]}
-Input query: {%s}
-Output:`, num_augmented_queries, query)
+	return GenerateAugmentedQueriesWithTemplate(query, num_augmented_queries, GetPromptTemplate("english"))
+}
+
+// GenerateAugmentedQueriesWithTemplate generates augmented queries with the
+// given prompt template.
+func GenerateAugmentedQueriesWithTemplate(query string, num_augmented_queries int, tmpl PromptTemplate) ([]string, error) {
+	messages, err := tmpl.Messages(query, num_augmented_queries)
+	if err != nil {
+		return nil, err
+	}
 
 	client := NewLLMClient(ClientConfig{
 		Provider:       "openai",
 		DeploymentName: "gpt-4o-mini",
 	})
 	request := ChatCompletionRequest{
-		Models: []string{"gpt-4o-mini"},
-		Messages: []ConvMessage{
-			{Role: "user", Content: strings.TrimSpace(prompt)},
-		},
+		Models:      []string{"gpt-4o-mini"},
+		Messages:    messages,
 		Stream:      false,
 		Temperature: 0.7,
 		MaxTokens:   200,
 	}
 
-	respChan, errChan := client.Completion(context.Background(), request)
-
-	select {
-	case responseTxt := <-respChan:
-		// log.Println("AUGMENTED QUERIES GENERATED")
-		// Clean up the response
-		cleanResponse := strings.TrimSpace(responseTxt)
-		cleanResponse = strings.TrimPrefix(cleanResponse, "```jsonl")
-		cleanResponse = strings.TrimSuffix(cleanResponse, "```")
-
-		// Parse the JSON response
-		var result struct {
-			Query            string   `json:"query"`
-			AugmentedQueries []string `json:"augmented queries"`
-		}
+	responseTxt, err := client.CompletionText(context.Background(), request)
+	if err != nil {
+		return nil, fmt.Errorf("error in generate_augmented_queries: %v", err)
+	}
+	// Clean up the response
+	cleanResponse := strings.TrimSpace(responseTxt)
+	cleanResponse = strings.TrimPrefix(cleanResponse, "```jsonl")
+	cleanResponse = strings.TrimPrefix(cleanResponse, "```json")
+	cleanResponse = strings.TrimSuffix(cleanResponse, "```")
+
+	// Parse the JSON response
+	var result struct {
+		Query            string   `json:"query"`
+		AugmentedQueries []string `json:"augmented queries"`
+	}
 
-		err := json.Unmarshal([]byte(cleanResponse), &result)
-		if err != nil {
-			// If JSON parsing fails, try to extract queries using a simple string split
-			queries := strings.Split(cleanResponse, "\",")
-			if len(queries) > 1 {
-				augmentedQueries := make([]string, 0, num_augmented_queries)
-				for i, q := range queries[1:] { // Skip the first element (original query)
-					if i >= num_augmented_queries {
-						break
-					}
-					q = strings.Trim(q, "[] \"\n")
-					if q != "" {
-						augmentedQueries = append(augmentedQueries, q)
-					}
+	err = json.Unmarshal([]byte(cleanResponse), &result)
+	if err != nil {
+		// If JSON parsing fails, try to extract queries using a simple string split
+		queries := strings.Split(cleanResponse, "\",")
+		if len(queries) > 1 {
+			augmentedQueries := make([]string, 0, num_augmented_queries)
+			for i, q := range queries[1:] { // Skip the first element (original query)
+				if i >= num_augmented_queries {
+					break
+				}
+				q = strings.Trim(q, "[] \"\n")
+				if q != "" {
+					augmentedQueries = append(augmentedQueries, q)
 				}
-				return augmentedQueries, nil
 			}
-			return nil, fmt.Errorf("error parsing LLM response: %v", err)
+			return augmentedQueries, nil
 		}
-
-		// Combine original query with augmented queries
-		augmentedQueries := append([]string{}, result.AugmentedQueries...)
-		return augmentedQueries, nil
-	case err := <-errChan:
-		log.Printf("Error in generate_augmented_queries: %v", err)
-		// Fallback: return the original query and some simple variations
-		return GenerateAugmentedQueries(query, num_augmented_queries)
+		return nil, fmt.Errorf("error parsing LLM response: %v", err)
 	}
+
+	augmentedQueries := append([]string{}, result.AugmentedQueries...)
+	return augmentedQueries, nil
 }
 
 type BMXAdapter struct {
-	indexName string
-	bmx       *BMX
+	indexName      string
+	bmx            *BMX
+	promptTemplate *PromptTemplate
+	augmentations  *AugmentationCache
 }
 
 type SearchResults struct {
 	Keys   []string
 	Scores []float64
+	Facets map[string]FacetResult
+	// TotalHits counts the documents with a positive score passing the
+	// filters; it is a lower bound when TotalHitsRelation is TotalHitsGTE.
+	TotalHits         int
+	TotalHitsRelation TotalHitsRelation
+	// Highlights holds the snippets of each hit when requested, in the order of Keys.
+	Highlights [][]Snippet
+	// Next is the cursor to pass as SearchAfter for the next page, nil when
+	// the page is not full.
+	Next *Cursor
 }
 
 func Build(indexName string, config text_preprocessor.Config) BMXAdapter {
@@ -97,8 +106,9 @@ func Build(indexName string, config text_preprocessor.Config) BMXAdapter {
 	bmx.InitializeTextPreprocessor(&config)
 
 	return BMXAdapter{
-		indexName: indexName,
-		bmx:       &bmx,
+		indexName:     indexName,
+		bmx:           &bmx,
+		augmentations: NewAugmentationCache(),
 	}
 }
 
@@ -111,54 +121,88 @@ func (adapter *BMXAdapter) AddMany(ids []string, docs []string) error {
 		}
 	}()
 
-	tokenize := adapter.bmx.TextPreprocessor.Process
-
+	vectors, err := adapter.embedTexts(docs)
+	if err != nil {
+		return err
+	}
 	for i, doc := range docs {
-		adapter.bmx.Docs[ids[i]] = Document{Text: doc, Tokens: tokenize(doc)}
+		document := Document{Text: doc}
+		lang := adapter.bmx.documentLanguage(doc, &document.Metadata)
+		document.Tokens = adapter.bmx.TextPreprocessor.ProcessLanguage(doc, lang)
+		adapter.bmx.Docs[ids[i]] = document
 	}
-	// fmt.Println("Setting params")
-	// start := time.Now()
-	adapter.bmx.SetParams()
-	// fmt.Println("Parameters set, total time:", time.Since(start))
-	// start = time.Now()
-	// fmt.Println("Filling F table")
-	adapter.bmx.F_table_fill()
-	// fmt.Println("F table filled, total time:", time.Since(start))
-	// start = time.Now()
-	// fmt.Println("Calculating number of appearances")
-	adapter.bmx.NumAppearancesCalc()
-	// fmt.Println("Number of appearances calculated, total time:", time.Since(start))
-	// start = time.Now()
-	// fmt.Println("Filling IDF table")
-	adapter.bmx.IDF_table_fill()
-	// fmt.Println("IDF table filled, total time:", time.Since(start))
-	// start = time.Now()
-	// fmt.Println("Filling E_tilde table")
-	adapter.bmx.E_tilde_table_fill()
-	// fmt.Println("E_tilde table filled, total time:", time.Since(start))
-	return nil
+	adapter.bmx.FillTables()
+	return adapter.storeVectors(ids[:len(docs)], vectors)
 }
 
-func (adapter *BMXAdapter) Search(query string, topK int) SearchResults {
-	q := Query{Text: query}
-	q.Initialize(adapter.bmx)
+// SearchOptions holds the per-query settings of SearchWithOptions.
+type SearchOptions struct {
+	TopK   int // Page size
+	Offset int // Number of hits skipped before the page
+	// SearchAfter returns the hits ranked after the cursor, usually the Next
+	// cursor of the previous page. Deep pages are cheaper than with Offset.
+	SearchAfter *Cursor
+	// TrackTotalHitsUpTo stops counting hits at this value, reported as a lower
+	// bound. 0 counts them all.
+	TrackTotalHitsUpTo int
+	// Normalization selects the reported scores, the BMX-normalised score by default.
+	Normalization ScoreNormalization
+	// MinScore drops the documents scoring below it instead of filling TopK
+	// with irrelevant ones; 0 disables it. It applies to the reported score,
+	// min-max and softmax being computed before the documents are dropped.
+	MinScore float64
+	// Highlight returns the best snippets of each hit with the matches marked.
+	Highlight *HighlightOptions
+	Scorer    Scorer         // Overrides the adapter's scorer for this query when set
+	Params    ParamOverrides // Pins Alpha, Beta, k1 and/or b for this query
+	// FieldBoosts multiplies the field weights of BMXF for this query. Without
+	// an explicit scorer, setting it selects BMXF.
+	FieldBoosts map[string]float64
+	Filter      Filter         // Restricts the results to documents whose metadata match
+	Facets      []FacetRequest // Aggregations over the documents matching the query
+	// ProximityBoost adds ProximityBoost / d to a document's score for each
+	// pair of consecutive query tokens found d positions apart. It needs the
+	// positional index.
+	ProximityBoost float64
+	// Expansion adds fuzzy and prefix matches of the query tokens, and sets the
+	// decay of the ~, * and ? operators of SearchQuery.
+	Expansion ExpansionOptions
+}
+
+// query builds the Query carrying the per-query options.
+func (opts SearchOptions) query(text string) Query {
+	return Query{Text: text, Scorer: opts.Scorer, Overrides: opts.Params, FieldBoosts: opts.FieldBoosts, Filter: opts.Filter, ProximityBoost: opts.ProximityBoost, Expansion: opts.Expansion}
+}
 
-	Keys := []string{}
-	for key := range q.ScoreTable {
-		Keys = append(Keys, key)
+// SetScorer sets the scoring function used by the adapter's queries.
+// The index statistics are shared by all scorers, so no rebuild is needed.
+func (adapter *BMXAdapter) SetScorer(scorer Scorer) {
+	adapter.bmx.Scorer = scorer
+}
+
+// SetParamOverrides pins the index's Alpha, Beta, k1 and/or b. Nil fields
+// go back to the values derived from the corpus or set on the scorer.
+func (adapter *BMXAdapter) SetParamOverrides(overrides ParamOverrides) {
+	adapter.bmx.Overrides = overrides
+	if len(adapter.bmx.Docs) > 0 {
+		adapter.bmx.SetParams()
 	}
+}
 
-	// Sort the indices based on the normalizedScoreTable in descending order
-	sort.Slice(Keys, func(i, j int) bool {
-		return q.ScoreTable[Keys[i]] > q.ScoreTable[Keys[j]]
-	})
+// Params returns the parameters currently used by the index.
+func (adapter *BMXAdapter) Params() Parameters {
+	return adapter.bmx.Params
+}
 
-	topKeys := Keys[:topK]
+func (adapter *BMXAdapter) Search(query string, topK int) SearchResults {
+	return adapter.SearchWithOptions(query, SearchOptions{TopK: topK})
+}
 
-	topScores := []float64{}
-	for _, key := range topKeys {
-		topScores = append(topScores, q.NormalizedScoreTable[key])
-	}
+func (adapter *BMXAdapter) SearchWithOptions(query string, opts SearchOptions) SearchResults {
+	q := opts.query(query)
+	q.Initialize(adapter.bmx)
+	results := topResults(&q, adapter.bmx, opts)
+	results.Facets = q.Facets(adapter.bmx, opts)
 
 	// fmt.Println("IDF:", q.IDF_table)
 	// fmt.Println("TF:", q.F_table)
@@ -167,7 +211,18 @@ func (adapter *BMXAdapter) Search(query string, topK int) SearchResults {
 	// fmt.Println("S_table:", q.S_table)
 	// fmt.Println("Scores:", q.ScoreTable)
 
-	return SearchResults{Keys: topKeys, Scores: topScores}
+	return results
+}
+
+// Explain breaks down the score of docID for the query, token by token.
+func (adapter *BMXAdapter) Explain(query string, docID string) (Explanation, error) {
+	return adapter.ExplainWithOptions(query, docID, SearchOptions{})
+}
+
+func (adapter *BMXAdapter) ExplainWithOptions(query string, docID string, opts SearchOptions) (Explanation, error) {
+	q := opts.query(query)
+	q.Initialize(adapter.bmx)
+	return q.Explain(adapter.bmx, docID)
 }
 
 func (adapter *BMXAdapter) SearchMany(queries []string, topK int, maxConcurrent int) []SearchResults {
@@ -193,49 +248,17 @@ func (adapter *BMXAdapter) SearchMany(queries []string, topK int, maxConcurrent
 	return results
 }
 
+// SearchAugmented searches query with augmented queries. When augmentation
+// fails, the error is logged and the results of the plain query are
+// returned; use SearchAugmentedWithOptions to handle it.
 func (adapter *BMXAdapter) SearchAugmented(query string, topK int, num_augmented_queries int, weight float64) SearchResults {
-	// fmt.Println("Generating augmented queries")
-	// start := time.Now()
-	augmentedQueries, err := GenerateAugmentedQueries(query, num_augmented_queries)
+	opts := SearchOptions{TopK: topK}
+	results, err := adapter.SearchAugmentedWithOptions(query, AugmentOptions{NumQueries: num_augmented_queries, Weight: weight}, opts)
 	if err != nil {
-		log.Fatal(err)
+		log.Printf("query augmentation failed, searching the original query: %v", err)
+		return adapter.SearchWithOptions(query, opts)
 	}
-	// fmt.Println("Augmented queries generated, total time:", time.Since(start))
-	q := Query{Text: query, AugmentedQueries: augmentedQueries}
-
-	q.AugmentedWeights = []float64{}
-	for range augmentedQueries {
-		q.AugmentedWeights = append(q.AugmentedWeights, weight)
-	}
-
-	// fmt.Println("Initializing query")
-	// start = time.Now()
-	q.Initialize(adapter.bmx)
-	// fmt.Println("Query initialized, total time:", time.Since(start))
-
-	// fmt.Println("Sorting keys")
-	// start = time.Now()
-	Keys := []string{}
-	for key := range q.ScoreTable {
-		Keys = append(Keys, key)
-	}
-
-	// Sort the indices based on the normalizedScoreTable in descending order
-	sort.Slice(Keys, func(i, j int) bool {
-		return q.ScoreTable[Keys[i]] > q.ScoreTable[Keys[j]]
-	})
-	// fmt.Println("Keys sorted, total time:", time.Since(start))
-
-	// fmt.Println("Selecting top keys")
-	// start = time.Now()
-	topKeys := Keys[:topK]
-
-	topScores := []float64{}
-	for _, key := range topKeys {
-		topScores = append(topScores, q.NormalizedScoreTable[key])
-	}
-	// fmt.Println("Top keys selected, total time:", time.Since(start))
-	return SearchResults{Keys: topKeys, Scores: topScores}
+	return results
 }
 
 func (adapter *BMXAdapter) SearchAugmentedMany(queries []string, topK int, num_augmented_queries int, weight float64, maxConcurrent int) []SearchResults {
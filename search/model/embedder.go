@@ -0,0 +1,101 @@
+package model
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Embedder turns texts into dense vectors.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// LLMEmbedder embeds texts through the OpenAI-compatible embeddings
+// endpoint of an LLMClient.
+type LLMEmbedder struct {
+	Client    *LLMClient
+	Model     string
+	BatchSize int // Texts per request, defaults to 64
+}
+
+func NewLLMEmbedder(config ClientConfig, model string) *LLMEmbedder {
+	return &LLMEmbedder{Client: NewLLMClient(config), Model: model, BatchSize: 64}
+}
+
+func (e *LLMEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	batchSize := e.BatchSize
+	if batchSize <= 0 {
+		batchSize = 64
+	}
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += batchSize {
+		batch, err := e.Client.Embeddings(ctx, e.Model, texts[start:min(start+batchSize, len(texts))])
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, batch...)
+	}
+	return vectors, nil
+}
+
+// FakeEmbedder is a deterministic local embedder for tests: it hashes the
+// lowercased words of a text into Dimensions buckets, so texts sharing words
+// get similar vectors.
+type FakeEmbedder struct {
+	Dimensions int // Defaults to 64
+}
+
+func (e FakeEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	dimensions := e.Dimensions
+	if dimensions <= 0 {
+		dimensions = 64
+	}
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vector := make([]float32, dimensions)
+		words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		for _, word := range words {
+			h := fnv.New64a()
+			h.Write([]byte(word))
+			sum := h.Sum64()
+			sign := float32(1)
+			if sum>>63 == 1 {
+				sign = -1
+			}
+			vector[sum%uint64(dimensions)] += sign
+		}
+		vectors[i] = normalizeVector(vector)
+	}
+	return vectors, nil
+}
+
+// normalizeVector returns a copy of vector with unit L2 norm, so cosine
+// similarity is a dot product.
+func normalizeVector(vector []float32) []float32 {
+	norm := 0.0
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+	normalized := make([]float32, len(vector))
+	if norm == 0 {
+		return normalized
+	}
+	inv := float32(1 / math.Sqrt(norm))
+	for i, v := range vector {
+		normalized[i] = v * inv
+	}
+	return normalized
+}
+
+func dot(a, b []float32) float64 {
+	sum := float32(0)
+	for i := range a {
+		sum += a[i] * b[i]
+	}
+	return float64(sum)
+}
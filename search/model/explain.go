@@ -0,0 +1,186 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Explanation is a node of a score breakdown: a value, what it is, and the
+// values it was computed from.
+type Explanation struct {
+	Value       float64
+	Description string
+	Details     []Explanation
+}
+
+func (e Explanation) String() string {
+	var sb strings.Builder
+	e.write(&sb, 0)
+	return sb.String()
+}
+
+func (e Explanation) write(sb *strings.Builder, depth int) {
+	fmt.Fprintf(sb, "%s%g = %s\n", strings.Repeat("  ", depth), e.Value, e.Description)
+	for _, detail := range e.Details {
+		detail.write(sb, depth+1)
+	}
+}
+
+func leaf(value float64, description string) Explanation {
+	return Explanation{Value: value, Description: description}
+}
+
+// sortedTokens returns the query tokens in a stable order.
+func (query *Query) sortedTokens() []string {
+	tokens := make([]string, 0, len(query.Tokens))
+	for token := range query.Tokens {
+		tokens = append(tokens, token)
+	}
+	sort.Strings(tokens)
+	return tokens
+}
+
+// Explain breaks down the score of docID for an initialized query.
+func (query *Query) Explain(bmx *BMX, docID string) (Explanation, error) {
+	if _, ok := bmx.Docs[docID]; !ok {
+		return Explanation{}, fmt.Errorf("document %s not found", docID)
+	}
+	explanation := query.scorer(bmx).Explain(query, bmx, docID)
+	if explanation.Value == 0 || !query.hasProximityBoost(bmx) {
+		return explanation, nil
+	}
+	boost := query.proximityBoost(bmx, docID)
+	return Explanation{
+		Value:       explanation.Value + boost,
+		Description: fmt.Sprintf("score of document %s with proximity boost, sum of:", docID),
+		Details: []Explanation{
+			explanation,
+			leaf(boost, "proximity boost, ProximityBoost * sum(1 / d), d the smallest distance between consecutive query tokens"),
+		},
+	}, nil
+}
+
+// explainSum wraps per-token explanations into the document score.
+func explainSum(scorer Scorer, docID string, tokens []Explanation) Explanation {
+	total := 0.0
+	for _, token := range tokens {
+		total += token.Value
+	}
+	return Explanation{
+		Value:       total,
+		Description: fmt.Sprintf("%s score of document %s, sum of:", scorer.Name(), docID),
+		Details:     tokens,
+	}
+}
+
+func (s BMXScorer) Explain(query *Query, bmx *BMX, docID string) Explanation {
+	params := query.params(bmx)
+	doc := bmx.Docs[docID]
+	dl := float64(len(doc.Tokens))
+	details := []Explanation{}
+	for _, qi := range query.sortedTokens() {
+		f, ok := doc.F_table[qi]
+		if !ok {
+			details = append(details, leaf(0, fmt.Sprintf("token %q: no match", qi)))
+			continue
+		}
+		weight := query.Tokens[qi]
+		idf := bmx.IDF_table[qi]
+		e := bmx.E_tilde_table[qi] / query.max_E_tilde
+		sim := query.S_table[docID]
+		tfNorm := float64(f) * (params.Alpha + 1) / (float64(f) + params.Alpha*dl/params.Avgdl + params.Alpha*query.avgEntropy)
+		entropyTerm := params.Beta * e * sim
+		details = append(details, Explanation{
+			Value:       weight * (idf*tfNorm + entropyTerm),
+			Description: fmt.Sprintf("token %q: weight * (idf * tfNorm + beta * E * S(Q,D)), from:", qi),
+			Details: []Explanation{
+				leaf(weight, "weight, original plus augmentation weights of the token"),
+				{
+					Value:       idf,
+					Description: "idf, log((N - n + 0.5) / (n + 0.5) + 1), from:",
+					Details: []Explanation{
+						leaf(float64(params.N), "N, number of documents"),
+						leaf(float64(len(bmx.NumAppearances[qi])), "n, number of documents containing the token"),
+					},
+				},
+				{
+					Value:       tfNorm,
+					Description: "tfNorm, f * (alpha + 1) / (f + alpha * dl / avgdl + alpha * avgEntropy), from:",
+					Details: []Explanation{
+						leaf(float64(f), "f, token frequency in document"),
+						leaf(params.Alpha, "alpha"),
+						leaf(dl, "dl, document length"),
+						leaf(params.Avgdl, "avgdl, average document length"),
+						leaf(query.avgEntropy, "avgEntropy, weighted average of the query tokens' normalised entropies"),
+					},
+				},
+				{
+					Value:       entropyTerm,
+					Description: "beta * E * S(Q,D), from:",
+					Details: []Explanation{
+						leaf(params.Beta, "beta"),
+						leaf(e, "E, token entropy normalised by the query's max entropy"),
+						leaf(sim, "S(Q,D), weighted fraction of query tokens present in document"),
+					},
+				},
+			},
+		})
+	}
+	return explainSum(s, docID, details)
+}
+
+// explainBM25Family explains scorers whose per-token score is idf times a
+// term frequency normalisation.
+func explainBM25Family(scorer Scorer, query *Query, bmx *BMX, docID string, formula string, tfNorm func(f, dl, avgdl float64) (float64, []Explanation)) Explanation {
+	doc := bmx.Docs[docID]
+	dl := float64(len(doc.Tokens))
+	details := []Explanation{}
+	for _, qi := range query.sortedTokens() {
+		f, ok := doc.F_table[qi]
+		if !ok {
+			details = append(details, leaf(0, fmt.Sprintf("token %q: no match", qi)))
+			continue
+		}
+		weight := query.Tokens[qi]
+		idf := bmx.IDF_table[qi]
+		norm, parts := tfNorm(float64(f), dl, bmx.Params.Avgdl)
+		parts = append([]Explanation{
+			leaf(float64(f), "f, token frequency in document"),
+			leaf(dl, "dl, document length"),
+			leaf(bmx.Params.Avgdl, "avgdl, average document length"),
+		}, parts...)
+		details = append(details, Explanation{
+			Value:       weight * idf * norm,
+			Description: fmt.Sprintf("token %q: weight * idf * tfNorm, from:", qi),
+			Details: []Explanation{
+				leaf(weight, "weight, original plus augmentation weights of the token"),
+				leaf(idf, "idf, log((N - n + 0.5) / (n + 0.5) + 1)"),
+				{Value: norm, Description: "tfNorm, " + formula + ", from:", Details: parts},
+			},
+		})
+	}
+	return explainSum(scorer, docID, details)
+}
+
+func (s BM25Scorer) Explain(query *Query, bmx *BMX, docID string) Explanation {
+	s.K1, s.B = query.bm25Params(bmx, s.K1, s.B)
+	return explainBM25Family(s, query, bmx, docID, "f * (k1 + 1) / (f + k1 * (1 - b + b * dl / avgdl))", func(f, dl, avgdl float64) (float64, []Explanation) {
+		return f * (s.K1 + 1) / (f + s.K1*(1-s.B+s.B*dl/avgdl)), []Explanation{leaf(s.K1, "k1"), leaf(s.B, "b")}
+	})
+}
+
+func (s BM25PlusScorer) Explain(query *Query, bmx *BMX, docID string) Explanation {
+	s.K1, s.B = query.bm25Params(bmx, s.K1, s.B)
+	return explainBM25Family(s, query, bmx, docID, "f * (k1 + 1) / (f + k1 * (1 - b + b * dl / avgdl)) + delta", func(f, dl, avgdl float64) (float64, []Explanation) {
+		return f*(s.K1+1)/(f+s.K1*(1-s.B+s.B*dl/avgdl)) + s.Delta, []Explanation{leaf(s.K1, "k1"), leaf(s.B, "b"), leaf(s.Delta, "delta")}
+	})
+}
+
+func (s BM25LScorer) Explain(query *Query, bmx *BMX, docID string) Explanation {
+	s.K1, s.B = query.bm25Params(bmx, s.K1, s.B)
+	return explainBM25Family(s, query, bmx, docID, "(k1 + 1) * (ctd + delta) / (k1 + ctd + delta), ctd = f / (1 - b + b * dl / avgdl)", func(f, dl, avgdl float64) (float64, []Explanation) {
+		ctd := f / (1 - s.B + s.B*dl/avgdl)
+		return (s.K1 + 1) * (ctd + s.Delta) / (s.K1 + ctd + s.Delta), []Explanation{leaf(s.K1, "k1"), leaf(s.B, "b"), leaf(s.Delta, "delta")}
+	})
+}
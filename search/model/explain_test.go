@@ -0,0 +1,37 @@
+package model
+
+import (
+	"math"
+	"testing"
+)
+
+// TestExplainTotals checks that every scorer explains the score it returns,
+// proximity boost included.
+func TestExplainTotals(t *testing.T) {
+	adapter := newTestAdapter(t, testDocs...)
+	scorers := []Scorer{BMXScorer{}, NewBM25Scorer(1.2, 0.75), NewBM25PlusScorer(1.2, 0.75, 1), NewBM25LScorer(1.2, 0.75, 0.5), NewBMXFScorer(nil)}
+	for _, scorer := range scorers {
+		for _, boost := range []float64{0, 0.5} {
+			q := Query{Text: "lazy brown dog", Scorer: scorer, ProximityBoost: boost}
+			q.Initialize(adapter.bmx)
+			for key, score := range q.ScoreTable {
+				explanation, err := q.Explain(adapter.bmx, key)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if math.Abs(explanation.Value-score) > 1e-9 {
+					t.Errorf("%s, proximity boost %g: explained score of %s = %g, want %g\n%s", scorer.Name(), boost, key, explanation.Value, score, explanation)
+				}
+			}
+		}
+	}
+}
+
+func TestExplainUnknownDocument(t *testing.T) {
+	adapter := newTestAdapter(t, testDocs...)
+	q := Query{Text: "fox"}
+	q.Initialize(adapter.bmx)
+	if _, err := q.Explain(adapter.bmx, "missing"); err == nil {
+		t.Error("Explain(missing) succeeded, want an error")
+	}
+}
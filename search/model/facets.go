@@ -0,0 +1,182 @@
+package model
+
+import (
+	"sort"
+	"strconv"
+	"time"
+)
+
+type FacetKind int
+
+const (
+	TermsFacetKind FacetKind = iota
+	RangeFacetKind
+	DateHistogramFacetKind
+)
+
+type DateInterval int
+
+const (
+	Year DateInterval = iota
+	Month
+	Day
+)
+
+// FacetRange is a numeric bucket [From, To).
+type FacetRange struct {
+	Key  string
+	From float64
+	To   float64
+}
+
+// FacetRequest describes an aggregation over the documents matching a search.
+type FacetRequest struct {
+	Name     string // Key of the result, defaults to Field
+	Field    string
+	Kind     FacetKind
+	Size     int // Max number of buckets of a terms facet, defaults to 10
+	Ranges   []FacetRange
+	Interval DateInterval
+}
+
+type FacetBucket struct {
+	Key   string
+	Count int
+}
+
+// FacetResult holds the buckets of a facet. Other counts the values of a
+// terms facet left out by its Size.
+type FacetResult struct {
+	Buckets []FacetBucket
+	Other   int
+}
+
+// TermsFacet counts the matching documents per keyword value.
+func TermsFacet(field string, size int) FacetRequest {
+	return FacetRequest{Field: field, Kind: TermsFacetKind, Size: size}
+}
+
+// RangeFacet counts the matching documents per numeric range.
+func RangeFacet(field string, ranges ...FacetRange) FacetRequest {
+	return FacetRequest{Field: field, Kind: RangeFacetKind, Ranges: ranges}
+}
+
+// DateHistogramFacet counts the matching documents per year, month or day.
+func DateHistogramFacet(field string, interval DateInterval) FacetRequest {
+	return FacetRequest{Field: field, Kind: DateHistogramFacetKind, Interval: interval}
+}
+
+// Matching returns the documents containing at least one positively weighted
+// query token and allowed by the query's candidates, from the postings.
+func (query *Query) Matching(bmx *BMX) *Bitmap {
+	matching := NewBitmap(len(bmx.DocIDs))
+	for qi, weight := range query.Tokens {
+		if weight <= 0 {
+			continue
+		}
+		for _, doc_key := range bmx.NumAppearances[qi] {
+			matching.Add(bmx.Ordinals[doc_key])
+		}
+	}
+	if query.Candidates != nil {
+		matching.And(query.Candidates)
+	}
+	return matching
+}
+
+// Facets computes the aggregations requested by opts over the matching
+// documents that a search with opts may return, so MinScore applies.
+func (query *Query) Facets(bmx *BMX, opts SearchOptions) map[string]FacetResult {
+	if len(opts.Facets) == 0 {
+		return nil
+	}
+	eligible, _ := query.eligible(bmx, opts)
+	matching := NewBitmap(len(bmx.DocIDs))
+	query.Matching(bmx).ForEach(func(ordinal int) {
+		if eligible(bmx.DocIDs[ordinal]) {
+			matching.Add(ordinal)
+		}
+	})
+	results := make(map[string]FacetResult, len(opts.Facets))
+	for _, request := range opts.Facets {
+		name := request.Name
+		if name == "" {
+			name = request.Field
+		}
+		switch request.Kind {
+		case TermsFacetKind:
+			results[name] = termsFacet(bmx, matching, request)
+		case RangeFacetKind:
+			results[name] = rangeFacet(bmx, matching, request)
+		case DateHistogramFacetKind:
+			results[name] = dateHistogramFacet(bmx, matching, request)
+		}
+	}
+	return results
+}
+
+func termsFacet(bmx *BMX, matching *Bitmap, request FacetRequest) FacetResult {
+	size := request.Size
+	if size <= 0 {
+		size = 10
+	}
+	buckets := []FacetBucket{}
+	for value, docs := range bmx.MetadataIndex.keywords[request.Field] {
+		if count := docs.AndCount(matching); count > 0 {
+			buckets = append(buckets, FacetBucket{Key: value, Count: count})
+		}
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		if buckets[i].Count != buckets[j].Count {
+			return buckets[i].Count > buckets[j].Count
+		}
+		return buckets[i].Key < buckets[j].Key
+	})
+	result := FacetResult{}
+	for i, bucket := range buckets {
+		if i < size {
+			result.Buckets = append(result.Buckets, bucket)
+		} else {
+			result.Other += bucket.Count
+		}
+	}
+	return result
+}
+
+func rangeFacet(bmx *BMX, matching *Bitmap, request FacetRequest) FacetResult {
+	entries := bmx.MetadataIndex.sorted[request.Field]
+	result := FacetResult{}
+	for _, r := range request.Ranges {
+		key := r.Key
+		if key == "" {
+			key = strconv.FormatFloat(r.From, 'g', -1, 64) + "-" + strconv.FormatFloat(r.To, 'g', -1, 64)
+		}
+		bucket := FacetBucket{Key: key}
+		start := sort.Search(len(entries), func(i int) bool { return entries[i].value >= r.From })
+		for i := start; i < len(entries) && entries[i].value < r.To; i++ {
+			if matching.Contains(entries[i].ordinal) {
+				bucket.Count++
+			}
+		}
+		result.Buckets = append(result.Buckets, bucket)
+	}
+	return result
+}
+
+func dateHistogramFacet(bmx *BMX, matching *Bitmap, request FacetRequest) FacetResult {
+	layout := map[DateInterval]string{Year: "2006", Month: "2006-01", Day: "2006-01-02"}[request.Interval]
+	result := FacetResult{}
+	// Entries are sorted by date, so buckets come out in chronological order.
+	for _, entry := range bmx.MetadataIndex.sorted[request.Field] {
+		if !matching.Contains(entry.ordinal) {
+			continue
+		}
+		key := time.UnixMilli(int64(entry.value)).UTC().Format(layout)
+		if n := len(result.Buckets); n > 0 && result.Buckets[n-1].Key == key {
+			result.Buckets[n-1].Count++
+		} else {
+			result.Buckets = append(result.Buckets, FacetBucket{Key: key, Count: 1})
+		}
+	}
+	return result
+}
@@ -0,0 +1,77 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFacets(t *testing.T) {
+	adapter := newMetadataAdapter(t)
+	tests := []struct {
+		name    string
+		query   string
+		request FacetRequest
+		want    FacetResult
+	}{
+		{
+			name:    "terms",
+			query:   "fox",
+			request: TermsFacet("tags", 10),
+			want:    FacetResult{Buckets: []FacetBucket{{"animal", 2}, {"plant", 1}, {"red", 1}}},
+		},
+		{
+			name:    "terms size",
+			query:   "fox",
+			request: TermsFacet("tags", 1),
+			want:    FacetResult{Buckets: []FacetBucket{{"animal", 2}}, Other: 2},
+		},
+		{
+			name:    "terms of the matching documents",
+			query:   "brown lazy",
+			request: TermsFacet("tags", 10),
+			want:    FacetResult{Buckets: []FacetBucket{{"animal", 1}, {"plant", 1}}},
+		},
+		{
+			name:    "range",
+			query:   "fox",
+			request: RangeFacet("price", FacetRange{Key: "cheap", From: 0, To: 20}, FacetRange{From: 20, To: 100}),
+			want:    FacetResult{Buckets: []FacetBucket{{"cheap", 1}, {"20-100", 2}}},
+		},
+		{
+			name:    "date histogram",
+			query:   "fox",
+			request: DateHistogramFacet("date", Day),
+			want:    FacetResult{Buckets: []FacetBucket{{"2024-01-01", 1}, {"2024-01-02", 1}, {"2024-01-03", 1}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := adapter.SearchWithOptions(tt.query, SearchOptions{TopK: 1, Facets: []FacetRequest{tt.request}})
+			if got := results.Facets[tt.request.Field]; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("facet = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestFacetsEligible checks that facets count the documents the search may
+// return: filtered out or scoring below MinScore, they are left out.
+func TestFacetsEligible(t *testing.T) {
+	adapter := newMetadataAdapter(t)
+	request := []FacetRequest{{Name: "tags", Field: "tags", Kind: TermsFacetKind}}
+	filtered := adapter.SearchWithOptions("fox", SearchOptions{TopK: 10, Facets: request, Filter: BoolFilter("stock", true)})
+	if want := []FacetBucket{{"animal", 1}, {"red", 1}}; !reflect.DeepEqual(filtered.Facets["tags"].Buckets, want) {
+		t.Errorf("filtered facet = %+v, want %+v", filtered.Facets["tags"].Buckets, want)
+	}
+
+	// Only "a" contains red, the other documents score below its score.
+	full := adapter.SearchWithOptions("red fox", SearchOptions{TopK: 10, Normalization: NormalizeRaw})
+	minScore := full.Scores[0]
+	results := adapter.SearchWithOptions("red fox", SearchOptions{TopK: 10, Normalization: NormalizeRaw, MinScore: minScore, Facets: request})
+	if len(results.Keys) != 1 || results.Keys[0] != "a" {
+		t.Fatalf("results above MinScore = %q, want [a]", results.Keys)
+	}
+	if want := []FacetBucket{{"animal", 1}, {"red", 1}}; !reflect.DeepEqual(results.Facets["tags"].Buckets, want) {
+		t.Errorf("facet above MinScore = %+v, want %+v", results.Facets["tags"].Buckets, want)
+	}
+}
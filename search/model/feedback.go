@@ -0,0 +1,89 @@
+package model
+
+import "sort"
+
+// FeedbackOptions configures RM3 pseudo-relevance feedback.
+type FeedbackOptions struct {
+	Docs           int     // Feedback documents, defaults to 10
+	Terms          int     // Expansion terms, defaults to 10
+	OriginalWeight float64 // Share of the original query in (0, 1], defaults to 0.5
+}
+
+func (fb FeedbackOptions) withDefaults() FeedbackOptions {
+	if fb.Docs <= 0 {
+		fb.Docs = 10
+	}
+	if fb.Terms <= 0 {
+		fb.Terms = 10
+	}
+	if fb.OriginalWeight <= 0 || fb.OriginalWeight > 1 {
+		fb.OriginalWeight = 0.5
+	}
+	return fb
+}
+
+// SearchWithFeedback expands query with RM3 pseudo-relevance feedback: the
+// top feedback documents of a first search give a relevance model
+// P(t|R) = sum_d P(d|q) tf(t,d)/|d|, whose best terms are added as augmented
+// queries so that they weigh 1-OriginalWeight of the final query.
+func (adapter *BMXAdapter) SearchWithFeedback(query string, fb FeedbackOptions, opts SearchOptions) SearchResults {
+	expanded := adapter.feedbackQuery(query, fb, opts)
+	results := topResults(&expanded, adapter.bmx, opts)
+	results.Facets = expanded.Facets(adapter.bmx, opts)
+	return results
+}
+
+// feedbackQuery returns the initialized query expanded by feedback.
+func (adapter *BMXAdapter) feedbackQuery(query string, fb FeedbackOptions, opts SearchOptions) Query {
+	fb = fb.withDefaults()
+	q := opts.query(query)
+	q.Initialize(adapter.bmx)
+	feedback := topResults(&q, adapter.bmx, SearchOptions{TopK: fb.Docs, Normalization: NormalizeRaw, MinScore: 1e-12})
+
+	expanded := opts.query(query)
+	expanded.language = q.language
+	for _, term := range adapter.bmx.relevanceModel(feedback, fb.Terms) {
+		// The original tokens weigh 1 each, so the expansion terms share
+		// TotalWeight*(1-λ)/λ.
+		weight := q.TotalWeight * term.Score * (1 - fb.OriginalWeight) / fb.OriginalWeight
+		expanded.Augment(WeightedQuery{Text: term.Key, Weight: weight, Tokens: []string{term.Key}})
+	}
+	expanded.Initialize(adapter.bmx)
+	return expanded
+}
+
+// relevanceModel returns the n most likely terms of the feedback documents,
+// with probabilities renormalised over these terms.
+func (bmx *BMX) relevanceModel(feedback SearchResults, n int) []Cursor {
+	total := 0.0
+	for _, score := range feedback.Scores {
+		total += score
+	}
+	if total == 0 {
+		return nil
+	}
+	weights := map[string]float64{}
+	for i, key := range feedback.Keys {
+		doc := bmx.Docs[key]
+		if len(doc.Tokens) == 0 {
+			continue
+		}
+		for term, tf := range doc.F_table {
+			weights[term] += feedback.Scores[i] / total * float64(tf) / float64(len(doc.Tokens))
+		}
+	}
+	terms := make([]Cursor, 0, len(weights))
+	for term, weight := range weights {
+		terms = append(terms, Cursor{Score: weight, Key: term})
+	}
+	sort.Slice(terms, func(i, j int) bool { return terms[i].before(terms[j]) })
+	terms = terms[:min(n, len(terms))]
+	sum := 0.0
+	for _, term := range terms {
+		sum += term.Score
+	}
+	for i := range terms {
+		terms[i].Score /= sum
+	}
+	return terms
+}
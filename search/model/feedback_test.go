@@ -0,0 +1,66 @@
+package model
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRelevanceModel(t *testing.T) {
+	adapter := newTestAdapter(t, "fox fox dog", "fox cat")
+	feedback := SearchResults{Keys: []string{"a", "b"}, Scores: []float64{3, 1}}
+	// P(t|R) = sum_d P(d|q) tf(t,d)/|d|: fox = 3/4*2/3 + 1/4*1/2 = 5/8,
+	// dog = 3/4*1/3 = 1/4, cat = 1/4*1/2 = 1/8.
+	tests := []struct {
+		n    int
+		want []Cursor
+	}{
+		{3, []Cursor{{5.0 / 8, "fox"}, {1.0 / 4, "dog"}, {1.0 / 8, "cat"}}},
+		{2, []Cursor{{5.0 / 7, "fox"}, {2.0 / 7, "dog"}}},
+	}
+	for _, tt := range tests {
+		got := adapter.bmx.relevanceModel(feedback, tt.n)
+		if len(got) != len(tt.want) {
+			t.Fatalf("relevanceModel(%d) = %v, want %v", tt.n, got, tt.want)
+		}
+		for i := range got {
+			if got[i].Key != tt.want[i].Key || math.Abs(got[i].Score-tt.want[i].Score) > 1e-12 {
+				t.Errorf("relevanceModel(%d) = %v, want %v", tt.n, got, tt.want)
+				break
+			}
+		}
+	}
+	if got := adapter.bmx.relevanceModel(SearchResults{}, 3); got != nil {
+		t.Errorf("relevanceModel without feedback = %v, want nil", got)
+	}
+}
+
+// TestFeedbackWeights checks that the expansion terms weigh 1-OriginalWeight
+// of the expanded query.
+func TestFeedbackWeights(t *testing.T) {
+	adapter := newTestAdapter(t, testDocs...)
+	for _, originalWeight := range []float64{0.25, 0.5, 0.9} {
+		q := adapter.feedbackQuery("lazy dog", FeedbackOptions{Docs: 2, Terms: 5, OriginalWeight: originalWeight}, SearchOptions{})
+		expansion := 0.0
+		for _, weight := range q.AugmentedWeights {
+			expansion += weight
+		}
+		if share := 2 / (2 + expansion); math.Abs(share-originalWeight) > 1e-9 {
+			t.Errorf("original query share = %g, want %g", share, originalWeight)
+		}
+		if len(q.AugmentedWeights) != 5 {
+			t.Errorf("%d expansion terms, want 5", len(q.AugmentedWeights))
+		}
+	}
+}
+
+func TestSearchWithFeedback(t *testing.T) {
+	adapter := newMetadataAdapter(t)
+	results := adapter.SearchWithFeedback("red", FeedbackOptions{Docs: 1}, SearchOptions{TopK: 10, Facets: []FacetRequest{TermsFacet("tags", 10)}})
+	// The feedback document "red fox" brings in the other fox documents.
+	if len(results.Keys) != 4 || results.Keys[0] != "a" || results.TotalHits != 4 {
+		t.Errorf("results = %q with %d hits, want a first of 4", results.Keys, results.TotalHits)
+	}
+	if got := results.Facets["tags"].Buckets; len(got) != 3 {
+		t.Errorf("facet buckets = %+v, want animal, plant and red", got)
+	}
+}
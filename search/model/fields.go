@@ -0,0 +1,268 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// FieldedDocument is a document made of named text fields (title, body, ...).
+type FieldedDocument struct {
+	ID       string
+	Fields   map[string]string
+	Metadata Metadata
+}
+
+// Field is the indexed content of one field of a document.
+type Field struct {
+	Text    string
+	Tokens  []string
+	F_table map[string]int
+}
+
+// FieldStats holds the per-field statistics used by BMXF.
+type FieldStats struct {
+	NumDocs       int
+	Avgdl         float64
+	E_tilde_table map[string]float64
+}
+
+// AddDocuments indexes multi-field documents. Each document is also indexed
+// as the concatenation of its fields, so every scorer can search it.
+func (adapter *BMXAdapter) AddDocuments(docs []FieldedDocument) error {
+	// Validate and embed every document before changing the index.
+	ids := make([]string, len(docs))
+	names := make([][]string, len(docs))
+	texts := make([]string, len(docs))
+	for i, doc := range docs {
+		if doc.ID == "" {
+			return fmt.Errorf("document %d without id", i)
+		}
+		ids[i] = doc.ID
+		for name := range doc.Fields {
+			names[i] = append(names[i], name)
+		}
+		sort.Strings(names[i])
+		fieldTexts := make([]string, len(names[i]))
+		for j, name := range names[i] {
+			fieldTexts[j] = doc.Fields[name]
+		}
+		texts[i] = strings.Join(fieldTexts, "\n")
+	}
+	vectors, err := adapter.embedTexts(texts)
+	if err != nil {
+		return err
+	}
+
+	for i, doc := range docs {
+		document := Document{Text: texts[i], Fields: make(map[string]Field, len(doc.Fields)), Metadata: doc.Metadata}
+		// Fields are analysed in the language of the whole document
+		lang := adapter.bmx.documentLanguage(texts[i], &document.Metadata)
+		for _, name := range names[i] {
+			field := Field{Text: doc.Fields[name], Tokens: adapter.bmx.TextPreprocessor.ProcessLanguage(doc.Fields[name], lang)}
+			document.Fields[name] = field
+			document.Tokens = append(document.Tokens, field.Tokens...)
+		}
+		adapter.bmx.Docs[doc.ID] = document
+	}
+	adapter.bmx.FillTables()
+	return adapter.storeVectors(ids, vectors)
+}
+
+func (bmx *BMX) Field_stats_fill() {
+	bmx.FieldStats = make(map[string]FieldStats)
+	totalLengths := map[string]int{}
+	for _, doc := range bmx.Docs {
+		for name, field := range doc.Fields {
+			stats, ok := bmx.FieldStats[name]
+			if !ok {
+				stats.E_tilde_table = make(map[string]float64)
+			}
+			stats.NumDocs++
+			totalLengths[name] += len(field.Tokens)
+			for _, qi := range field.Tokens {
+				pj := 1 / (1 + math.Exp(float64(-field.F_table[qi])))
+				stats.E_tilde_table[qi] += -pj * math.Log(pj)
+			}
+			bmx.FieldStats[name] = stats
+		}
+	}
+	for name, stats := range bmx.FieldStats {
+		stats.Avgdl = float64(totalLengths[name]) / float64(stats.NumDocs)
+		bmx.FieldStats[name] = stats
+	}
+}
+
+// BMXFScorer is a field-weighted BMX in the spirit of BM25F: the term
+// frequencies of each field are length-normalised with the field's own
+// average length, weighted and summed into a pseudo-frequency that replaces
+// f in the BMX formula. Token entropies are the weighted average of the
+// per-field entropies. Documents indexed without fields are scored as a
+// single field with weight 1.
+type BMXFScorer struct {
+	Weights map[string]float64 // Field weights, 1 for missing fields
+}
+
+func NewBMXFScorer(weights map[string]float64) BMXFScorer {
+	return BMXFScorer{Weights: weights}
+}
+
+func (BMXFScorer) Name() string { return "bmxf" }
+
+// weight returns the field weight including the query's boost.
+func (s BMXFScorer) weight(query *Query, name string) float64 {
+	weight := 1.0
+	if w, ok := s.Weights[name]; ok {
+		weight = w
+	}
+	if boost, ok := query.FieldBoosts[name]; ok {
+		weight *= boost
+	}
+	return weight
+}
+
+// entropies returns the field-weighted entropy of each query token normalised
+// by the query's max, and the weighted average over the query.
+func (s BMXFScorer) entropies(query *Query, bmx *BMX) (map[string]float64, float64) {
+	entropy := make(map[string]float64, len(query.Tokens))
+	maxEntropy := 0.0
+	for qi := range query.Tokens {
+		if len(bmx.FieldStats) == 0 {
+			entropy[qi] = bmx.E_tilde_table[qi]
+		} else {
+			totalWeight := 0.0
+			for name, stats := range bmx.FieldStats {
+				weight := s.weight(query, name)
+				entropy[qi] += weight * stats.E_tilde_table[qi]
+				totalWeight += weight
+			}
+			if totalWeight > 0 {
+				entropy[qi] /= totalWeight
+			}
+		}
+		maxEntropy = max(maxEntropy, entropy[qi])
+	}
+	avgEntropy := 0.0
+	for qi, weight := range query.Tokens {
+		if maxEntropy > 0 {
+			entropy[qi] /= maxEntropy
+		}
+		avgEntropy += entropy[qi] * weight
+	}
+	if query.TotalWeight == 0 {
+		return entropy, 0
+	}
+	return entropy, avgEntropy / query.TotalWeight
+}
+
+// pseudoFrequency returns the weighted, length-normalised frequency of qi in
+// the document and the per-field contributions.
+func (s BMXFScorer) pseudoFrequency(query *Query, bmx *BMX, doc Document, qi string) (float64, map[string]float64) {
+	if len(doc.Fields) == 0 {
+		tf := float64(doc.F_table[qi]) * bmx.Params.Avgdl / float64(len(doc.Tokens))
+		return tf, map[string]float64{"": tf}
+	}
+	tf := 0.0
+	parts := map[string]float64{}
+	for name, field := range doc.Fields {
+		f := field.F_table[qi]
+		if f == 0 {
+			continue
+		}
+		part := s.weight(query, name) * float64(f) * bmx.FieldStats[name].Avgdl / float64(len(field.Tokens))
+		parts[name] = part
+		tf += part
+	}
+	return tf, parts
+}
+
+func (s BMXFScorer) Score(query *Query, bmx *BMX) map[string]float64 {
+	scores := make(map[string]float64, len(bmx.Docs))
+	for doc_key := range bmx.Docs {
+		scores[doc_key] = 0.0
+	}
+	params := query.params(bmx)
+	entropy, avgEntropy := s.entropies(query, bmx)
+	alpha1 := params.Alpha + 1.0
+	for qi, weight := range query.Tokens {
+		idf := bmx.IDF_table[qi]
+		betaE := params.Beta * entropy[qi]
+		for _, doc_key := range bmx.NumAppearances[qi] {
+			tf, _ := s.pseudoFrequency(query, bmx, bmx.Docs[doc_key], qi)
+			scores[doc_key] += weight * (idf*(tf*alpha1/(tf+params.Alpha+params.Alpha*avgEntropy)) + betaE*query.S_table[doc_key])
+		}
+	}
+	return scores
+}
+
+func (s BMXFScorer) Explain(query *Query, bmx *BMX, docID string) Explanation {
+	params := query.params(bmx)
+	entropy, avgEntropy := s.entropies(query, bmx)
+	doc := bmx.Docs[docID]
+	details := []Explanation{}
+	for _, qi := range query.sortedTokens() {
+		if _, ok := doc.F_table[qi]; !ok {
+			details = append(details, leaf(0, fmt.Sprintf("token %q: no match", qi)))
+			continue
+		}
+		weight := query.Tokens[qi]
+		idf := bmx.IDF_table[qi]
+		sim := query.S_table[docID]
+		tf, parts := s.pseudoFrequency(query, bmx, doc, qi)
+		tfNorm := tf * (params.Alpha + 1) / (tf + params.Alpha + params.Alpha*avgEntropy)
+		entropyTerm := params.Beta * entropy[qi] * sim
+
+		names := make([]string, 0, len(parts))
+		for name := range parts {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fieldDetails := []Explanation{}
+		for _, name := range names {
+			if name == "" {
+				fieldDetails = append(fieldDetails, leaf(parts[name], "f * avgdl / dl, document without fields"))
+				continue
+			}
+			field := doc.Fields[name]
+			fieldDetails = append(fieldDetails, Explanation{
+				Value:       parts[name],
+				Description: fmt.Sprintf("field %q: w * f * avgdl / dl, from:", name),
+				Details: []Explanation{
+					leaf(s.weight(query, name), "w, field weight times query boost"),
+					leaf(float64(field.F_table[qi]), "f, token frequency in field"),
+					leaf(bmx.FieldStats[name].Avgdl, "avgdl, average field length"),
+					leaf(float64(len(field.Tokens)), "dl, field length"),
+				},
+			})
+		}
+
+		details = append(details, Explanation{
+			Value:       weight * (idf*tfNorm + entropyTerm),
+			Description: fmt.Sprintf("token %q: weight * (idf * tfNorm + beta * E * S(Q,D)), from:", qi),
+			Details: []Explanation{
+				leaf(weight, "weight, original plus augmentation weights of the token"),
+				leaf(idf, "idf, log((N - n + 0.5) / (n + 0.5) + 1)"),
+				{
+					Value:       tfNorm,
+					Description: "tfNorm, tf * (alpha + 1) / (tf + alpha + alpha * avgEntropy), from:",
+					Details: []Explanation{
+						{Value: tf, Description: "tf, sum of the weighted length-normalised field frequencies:", Details: fieldDetails},
+						leaf(params.Alpha, "alpha"),
+						leaf(avgEntropy, "avgEntropy, weighted average of the query tokens' field-weighted entropies"),
+					},
+				},
+				{
+					Value:       entropyTerm,
+					Description: "beta * E * S(Q,D), from:",
+					Details: []Explanation{
+						leaf(params.Beta, "beta"),
+						leaf(entropy[qi], "E, field-weighted token entropy normalised by the query's max"),
+						leaf(sim, "S(Q,D), weighted fraction of query tokens present in document"),
+					},
+				},
+			},
+		})
+	}
+	return explainSum(s, docID, details)
+}
@@ -0,0 +1,82 @@
+package model
+
+import (
+	"math"
+	"testing"
+)
+
+var fieldedDocs = []FieldedDocument{
+	{ID: "title", Fields: map[string]string{"title": "fox", "body": "a story about an animal in the woods"}},
+	{ID: "body", Fields: map[string]string{"title": "a story", "body": "the fox runs in the woods"}},
+	{ID: "other", Fields: map[string]string{"title": "bears", "body": "brown bears eat fish"}},
+}
+
+func TestAddDocuments(t *testing.T) {
+	adapter := newTestAdapter(t)
+	if err := adapter.AddDocuments(fieldedDocs); err != nil {
+		t.Fatal(err)
+	}
+	doc := adapter.bmx.Docs["body"]
+	if len(doc.Fields) != 2 || doc.Text != "the fox runs in the woods\na story" {
+		t.Errorf("document = %q with %d fields, want the fields joined in name order", doc.Text, len(doc.Fields))
+	}
+	if stats := adapter.bmx.FieldStats["title"]; stats.NumDocs != 3 || stats.Avgdl != 4.0/3 {
+		t.Errorf("title stats = %+v, want 3 documents of average length 4/3", stats)
+	}
+	// The concatenated fields are searchable by every scorer.
+	if results := adapter.SearchWithOptions("fox", SearchOptions{TopK: 10, Scorer: NewBM25Scorer(1.2, 0.75)}); results.TotalHits != 2 {
+		t.Errorf("BM25 search for fox hits %d documents, want both fox documents", results.TotalHits)
+	}
+}
+
+func TestAddDocumentsWithoutID(t *testing.T) {
+	adapter := newTestAdapter(t)
+	docs := []FieldedDocument{fieldedDocs[0], {Fields: map[string]string{"title": "no id"}}}
+	if err := adapter.AddDocuments(docs); err == nil {
+		t.Fatal("AddDocuments without id succeeded, want an error")
+	}
+	if len(adapter.bmx.Docs) != 0 {
+		t.Errorf("index holds %d documents after a failed AddDocuments, want 0", len(adapter.bmx.Docs))
+	}
+}
+
+func TestBMXFFieldWeights(t *testing.T) {
+	adapter := newTestAdapter(t)
+	if err := adapter.AddDocuments(fieldedDocs); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		opts SearchOptions
+		want string
+	}{
+		{"title weight", SearchOptions{TopK: 10, Scorer: NewBMXFScorer(map[string]float64{"title": 5})}, "title"},
+		{"body weight", SearchOptions{TopK: 10, Scorer: NewBMXFScorer(map[string]float64{"body": 5})}, "body"},
+		{"title boost", SearchOptions{TopK: 10, FieldBoosts: map[string]float64{"title": 5}}, "title"},
+		{"body boost", SearchOptions{TopK: 10, FieldBoosts: map[string]float64{"body": 5}}, "body"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := adapter.SearchWithOptions("fox", tt.opts)
+			if results.TotalHits != 2 || results.Keys[0] != tt.want {
+				t.Errorf("results = %q with %d hits, want %s first of the two fox documents", results.Keys, results.TotalHits, tt.want)
+			}
+		})
+	}
+}
+
+// TestBMXFNoTokens checks that a query without tokens scores every document
+// 0 rather than NaN.
+func TestBMXFNoTokens(t *testing.T) {
+	adapter := newTestAdapter(t)
+	if err := adapter.AddDocuments(fieldedDocs); err != nil {
+		t.Fatal(err)
+	}
+	q := Query{Text: "", Scorer: NewBMXFScorer(nil)}
+	q.Initialize(adapter.bmx)
+	for key, score := range q.ScoreTable {
+		if score != 0 || math.IsNaN(score) {
+			t.Errorf("score of %s = %g, want 0", key, score)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package model
+
+import (
+	"math"
+	"time"
+)
+
+// Filter restricts a search to the documents whose metadata match. Filters
+// are evaluated on the metadata bitmaps before top-k selection.
+type Filter interface {
+	Bitmap(bmx *BMX) *Bitmap
+}
+
+type keywordFilter struct {
+	field  string
+	values []string
+}
+
+type rangeFilter struct {
+	field    string
+	min, max float64
+}
+
+type boolFilter struct {
+	field string
+	value bool
+}
+
+type existsFilter struct {
+	field string
+}
+
+type andFilter []Filter
+
+type orFilter []Filter
+
+type notFilter struct {
+	filter Filter
+}
+
+// KeywordFilter matches documents having any of the values.
+func KeywordFilter(field string, values ...string) Filter {
+	return keywordFilter{field: field, values: values}
+}
+
+// NumericRangeFilter matches documents with min <= value <= max. Use
+// math.Inf for open bounds.
+func NumericRangeFilter(field string, min, max float64) Filter {
+	return rangeFilter{field: field, min: min, max: max}
+}
+
+// DateRangeFilter matches documents with from <= date <= to. A zero time
+// leaves the bound open.
+func DateRangeFilter(field string, from, to time.Time) Filter {
+	min, max := math.Inf(-1), math.Inf(1)
+	if !from.IsZero() {
+		min = float64(from.UnixMilli())
+	}
+	if !to.IsZero() {
+		max = float64(to.UnixMilli())
+	}
+	return rangeFilter{field: field, min: min, max: max}
+}
+
+func BoolFilter(field string, value bool) Filter {
+	return boolFilter{field: field, value: value}
+}
+
+// ExistsFilter matches documents having the attribute.
+func ExistsFilter(field string) Filter {
+	return existsFilter{field: field}
+}
+
+func AndFilter(filters ...Filter) Filter {
+	return andFilter(filters)
+}
+
+func OrFilter(filters ...Filter) Filter {
+	return orFilter(filters)
+}
+
+func NotFilter(filter Filter) Filter {
+	return notFilter{filter: filter}
+}
+
+func (f keywordFilter) Bitmap(bmx *BMX) *Bitmap {
+	result := NewBitmap(len(bmx.DocIDs))
+	for _, value := range f.values {
+		if values, ok := bmx.MetadataIndex.keywords[f.field][value]; ok {
+			result.Or(values)
+		}
+	}
+	return result
+}
+
+func (f rangeFilter) Bitmap(bmx *BMX) *Bitmap {
+	return bmx.MetadataIndex.rangeBitmap(f.field, f.min, f.max, len(bmx.DocIDs))
+}
+
+func (f boolFilter) Bitmap(bmx *BMX) *Bitmap {
+	if values, ok := bmx.MetadataIndex.bools[f.field]; ok {
+		return values[f.value].Clone()
+	}
+	return NewBitmap(len(bmx.DocIDs))
+}
+
+func (f existsFilter) Bitmap(bmx *BMX) *Bitmap {
+	if present, ok := bmx.MetadataIndex.present[f.field]; ok {
+		return present.Clone()
+	}
+	return NewBitmap(len(bmx.DocIDs))
+}
+
+func (f andFilter) Bitmap(bmx *BMX) *Bitmap {
+	result := FullBitmap(len(bmx.DocIDs))
+	for _, filter := range f {
+		result.And(filter.Bitmap(bmx))
+	}
+	return result
+}
+
+func (f orFilter) Bitmap(bmx *BMX) *Bitmap {
+	result := NewBitmap(len(bmx.DocIDs))
+	for _, filter := range f {
+		result.Or(filter.Bitmap(bmx))
+	}
+	return result
+}
+
+func (f notFilter) Bitmap(bmx *BMX) *Bitmap {
+	return FullBitmap(len(bmx.DocIDs)).AndNot(f.filter.Bitmap(bmx))
+}
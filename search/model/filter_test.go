@@ -0,0 +1,60 @@
+package model
+
+import (
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+func day(d int) time.Time {
+	return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC)
+}
+
+func newMetadataAdapter(t *testing.T) BMXAdapter {
+	t.Helper()
+	adapter := newTestAdapter(t)
+	ids := []string{"a", "b", "c", "d"}
+	docs := []string{"red fox", "brown fox", "lazy fox", "quick fox"}
+	metadata := []Metadata{
+		{"tags": KeywordAttr("animal", "red"), "price": NumericAttr(10), "date": DateAttr(day(1)), "stock": BoolAttr(true)},
+		{"tags": KeywordAttr("animal"), "price": NumericAttr(20), "date": DateAttr(day(2)), "stock": BoolAttr(false)},
+		{"tags": KeywordAttr("plant"), "price": NumericAttr(30), "date": DateAttr(day(3))},
+		{},
+	}
+	if err := adapter.AddManyWithMetadata(ids, docs, metadata); err != nil {
+		t.Fatal(err)
+	}
+	return adapter
+}
+
+func TestFilters(t *testing.T) {
+	adapter := newMetadataAdapter(t)
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{"keyword", KeywordFilter("tags", "animal"), []string{"a", "b"}},
+		{"keyword any value", KeywordFilter("tags", "red", "plant"), []string{"a", "c"}},
+		{"keyword unknown field", KeywordFilter("color", "red"), []string{}},
+		{"numeric range", NumericRangeFilter("price", 15, 30), []string{"b", "c"}},
+		{"numeric open bound", NumericRangeFilter("price", math.Inf(-1), 20), []string{"a", "b"}},
+		{"date range", DateRangeFilter("date", day(2), time.Time{}), []string{"b", "c"}},
+		{"bool", BoolFilter("stock", false), []string{"b"}},
+		{"exists", ExistsFilter("stock"), []string{"a", "b"}},
+		{"and", AndFilter(KeywordFilter("tags", "animal"), NumericRangeFilter("price", 15, 100)), []string{"b"}},
+		{"or", OrFilter(BoolFilter("stock", true), KeywordFilter("tags", "plant")), []string{"a", "c"}},
+		{"not", NotFilter(ExistsFilter("tags")), []string{"d"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := adapter.SearchWithOptions("fox", SearchOptions{TopK: 10, Filter: tt.filter})
+			got := slices.Clone(results.Keys)
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("results = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package model
+
+type FusionMethod int
+
+const (
+	// FusionRRF is reciprocal rank fusion, which only uses the ranks.
+	FusionRRF FusionMethod = iota
+	// FusionWeighted sums the min-max normalised scores with weights.
+	FusionWeighted
+	// FusionCombMNZ is FusionWeighted multiplied by the number of lists
+	// in which the document appears.
+	FusionCombMNZ
+)
+
+// FusionCombSUM is the usual name of FusionWeighted.
+const FusionCombSUM = FusionWeighted
+
+// FuseOptions configures Fuse.
+type FuseOptions struct {
+	Method  FusionMethod
+	Weights []float64 // Weight per list, defaults to 1
+	RRFK    float64   // RRF constant, defaults to 60
+	Offset  int
+	TopK    int
+}
+
+// Fuse combines ranked lists, for instance from several queries or several
+// adapters, into a single ranking. Keys are compared as is, so lists from
+// different indexes should use unique ids.
+func Fuse(lists []SearchResults, opts FuseOptions) SearchResults {
+	return rankScores(fusedScores(lists, opts), opts.Offset, opts.TopK)
+}
+
+// fusedScores returns the fused score of every key of the lists.
+func fusedScores(lists []SearchResults, opts FuseOptions) map[string]float64 {
+	weights := make([]float64, len(lists))
+	for i := range weights {
+		weights[i] = 1
+		if i < len(opts.Weights) {
+			weights[i] = opts.Weights[i]
+		}
+	}
+	rrfK := opts.RRFK
+	if rrfK <= 0 {
+		rrfK = 60
+	}
+	var scores map[string]float64
+	switch opts.Method {
+	case FusionWeighted:
+		scores = weightedScores(lists, weights)
+	case FusionCombMNZ:
+		scores = weightedScores(lists, weights)
+		for key, count := range hitCounts(lists) {
+			scores[key] *= float64(count)
+		}
+	default:
+		scores = rrfScores(lists, weights, rrfK)
+	}
+	return scores
+}
+
+// rrfScores combines ranked lists by reciprocal rank fusion:
+// sum of weight / (k + rank), ranks starting at 1.
+func rrfScores(lists []SearchResults, weights []float64, k float64) map[string]float64 {
+	scores := map[string]float64{}
+	for i, list := range lists {
+		for rank, key := range list.Keys {
+			scores[key] += weights[i] / (k + float64(rank+1))
+		}
+	}
+	return scores
+}
+
+// weightedScores combines lists by the weighted sum of their min-max
+// normalised scores.
+func weightedScores(lists []SearchResults, weights []float64) map[string]float64 {
+	scores := map[string]float64{}
+	for i, list := range lists {
+		normalized := minMaxScores(list.Scores)
+		for j, key := range list.Keys {
+			scores[key] += weights[i] * normalized[j]
+		}
+	}
+	return scores
+}
+
+// hitCounts returns the number of lists containing each key.
+func hitCounts(lists []SearchResults) map[string]int {
+	counts := map[string]int{}
+	for _, list := range lists {
+		for _, key := range list.Keys {
+			counts[key]++
+		}
+	}
+	return counts
+}
+
+func minMaxScores(scores []float64) []float64 {
+	normalized := make([]float64, len(scores))
+	if len(scores) == 0 {
+		return normalized
+	}
+	lowest, highest := scores[0], scores[0]
+	for _, score := range scores {
+		lowest, highest = min(lowest, score), max(highest, score)
+	}
+	for i, score := range scores {
+		if highest == lowest {
+			normalized[i] = 1
+		} else {
+			normalized[i] = (score - lowest) / (highest - lowest)
+		}
+	}
+	return normalized
+}
+
+// rankScores returns the page [offset, offset+topK) of the keys ranked by
+// decreasing score, ties broken by key.
+func rankScores(scores map[string]float64, offset int, topK int) SearchResults {
+	q := Query{ScoreTable: scores}
+	offset = max(offset, 0)
+	hits := q.ranked(offset+topK, nil, nil)
+	results := SearchResults{Keys: []string{}, Scores: []float64{}, TotalHits: len(scores), TotalHitsRelation: TotalHitsEqual}
+	for _, hit := range hits[min(offset, len(hits)):] {
+		results.Keys = append(results.Keys, hit.Key)
+		results.Scores = append(results.Scores, hit.Score)
+	}
+	return results
+}
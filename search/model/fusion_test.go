@@ -0,0 +1,139 @@
+package model
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestFuse(t *testing.T) {
+	lists := []SearchResults{
+		{Keys: []string{"a", "b", "c"}, Scores: []float64{3, 2, 1}},
+		{Keys: []string{"b", "d"}, Scores: []float64{10, 5}},
+	}
+	tests := []struct {
+		name       string
+		opts       FuseOptions
+		wantKeys   []string
+		wantScores []float64
+	}{
+		{
+			name:       "rrf",
+			opts:       FuseOptions{TopK: 10},
+			wantKeys:   []string{"b", "a", "d", "c"},
+			wantScores: []float64{1.0/62 + 1.0/61, 1.0 / 61, 1.0 / 62, 1.0 / 63},
+		},
+		{
+			name:       "rrf weighted",
+			opts:       FuseOptions{Weights: []float64{1, 3}, TopK: 10},
+			wantKeys:   []string{"b", "d", "a", "c"},
+			wantScores: []float64{1.0/62 + 3.0/61, 3.0 / 62, 1.0 / 61, 1.0 / 63},
+		},
+		{
+			name:       "rrf k",
+			opts:       FuseOptions{RRFK: 1, TopK: 2},
+			wantKeys:   []string{"b", "a"},
+			wantScores: []float64{1.0/3 + 1.0/2, 1.0 / 2},
+		},
+		{
+			name:       "rrf page",
+			opts:       FuseOptions{Offset: 1, TopK: 2},
+			wantKeys:   []string{"a", "d"},
+			wantScores: []float64{1.0 / 61, 1.0 / 62},
+		},
+		{
+			name:       "weighted",
+			opts:       FuseOptions{Method: FusionWeighted, TopK: 10},
+			wantKeys:   []string{"b", "a", "c", "d"},
+			wantScores: []float64{1.5, 1, 0, 0},
+		},
+		{
+			name:       "combmnz",
+			opts:       FuseOptions{Method: FusionCombMNZ, TopK: 10},
+			wantKeys:   []string{"b", "a", "c", "d"},
+			wantScores: []float64{3, 1, 0, 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := Fuse(lists, tt.opts)
+			if !slices.Equal(results.Keys, tt.wantKeys) {
+				t.Errorf("Fuse keys = %v, want %v", results.Keys, tt.wantKeys)
+			}
+			for i, score := range results.Scores {
+				if i < len(tt.wantScores) && math.Abs(score-tt.wantScores[i]) > 1e-12 {
+					t.Errorf("Fuse score of %s = %g, want %g", results.Keys[i], score, tt.wantScores[i])
+				}
+			}
+			if results.TotalHits != 4 {
+				t.Errorf("Fuse TotalHits = %d, want 4", results.TotalHits)
+			}
+		})
+	}
+}
+
+func TestSearchWithSeparateAugmentations(t *testing.T) {
+	adapter := newTestAdapter(t, testDocs...)
+	augmentations := []WeightedQuery{{Text: "cats play", Weight: 1}, {Text: "hound", Weight: 0.5}}
+	for _, fusion := range []FusionMethod{FusionRRF, FusionWeighted, FusionCombMNZ} {
+		results := adapter.SearchWithAugmentations("lazy dog", augmentations, AugmentOptions{Separate: true, Fusion: fusion}, SearchOptions{TopK: 10})
+		got := map[string]bool{}
+		for _, key := range results.Keys {
+			got[key] = true
+		}
+		// a and c match the original query, e the first augmentation and f
+		// the second.
+		for _, key := range []string{"a", "c", "e", "f"} {
+			if !got[key] {
+				t.Errorf("fusion %d: %s missing from %v", fusion, key, results.Keys)
+			}
+		}
+		if len(results.Keys) == 0 || results.Keys[0] != "c" && results.Keys[0] != "a" {
+			t.Errorf("fusion %d: %v, want a document of the original query first", fusion, results.Keys)
+		}
+	}
+}
+
+// TestSearchWithAugmentationsOptions checks that merged and separate
+// augmentations both apply the search options.
+func TestSearchWithAugmentationsOptions(t *testing.T) {
+	adapter := newMetadataAdapter(t)
+	augmentations := []WeightedQuery{{Text: "lazy", Weight: 0.5}}
+	for _, separate := range []bool{false, true} {
+		aug := AugmentOptions{Separate: separate}
+		search := func(opts SearchOptions) SearchResults {
+			return adapter.SearchWithAugmentations("red fox", augmentations, aug, opts)
+		}
+		full := search(SearchOptions{TopK: 10, Normalization: NormalizeRaw, Facets: []FacetRequest{TermsFacet("tags", 10)}})
+		if len(full.Keys) != 4 {
+			t.Fatalf("separate %v: results = %q, want the 4 documents", separate, full.Keys)
+		}
+		if got := full.Facets["tags"].Buckets; len(got) != 3 {
+			t.Errorf("separate %v: facet buckets = %+v, want animal, plant and red", separate, got)
+		}
+
+		var byCursor []string
+		var after *Cursor
+		for range full.Keys {
+			page := search(SearchOptions{TopK: 3, SearchAfter: after})
+			byCursor = append(byCursor, page.Keys...)
+			if after = page.Next; after == nil {
+				break
+			}
+		}
+		if !slices.Equal(byCursor, full.Keys) {
+			t.Errorf("separate %v: cursor pages = %q, want %q", separate, byCursor, full.Keys)
+		}
+
+		above := search(SearchOptions{TopK: 10, Normalization: NormalizeRaw, MinScore: full.Scores[1]})
+		if len(above.Keys) != 2 || above.TotalHits != 2 {
+			t.Errorf("separate %v: MinScore kept %q (TotalHits %d), want the best 2", separate, above.Keys, above.TotalHits)
+		}
+		if minMax := search(SearchOptions{TopK: 10, Normalization: NormalizeMinMax}); minMax.Scores[0] != 1 {
+			t.Errorf("separate %v: min-max scores = %v, want 1 first", separate, minMax.Scores)
+		}
+		if capped := search(SearchOptions{TopK: 1, TrackTotalHitsUpTo: 2}); capped.TotalHits != 2 || capped.TotalHitsRelation != TotalHitsGTE {
+			t.Errorf("separate %v: TotalHits = %d %s, want 2 gte", separate, capped.TotalHits, capped.TotalHitsRelation)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package model
+
+import (
+	"testing"
+
+	"BMXGo/search/text_preprocessor"
+)
+
+// newTestAdapter indexes docs, keyed "a", "b", ... in order, with the
+// "simple" analyzer (no stopwords, no stemming) and positions stored.
+func newTestAdapter(t *testing.T, docs ...string) BMXAdapter {
+	t.Helper()
+	config, err := text_preprocessor.NewAnalyzerConfig("simple", "english")
+	if err != nil {
+		t.Fatal(err)
+	}
+	adapter := Build("test", *config)
+	adapter.EnablePositionalIndex()
+	ids := make([]string, len(docs))
+	for i := range docs {
+		ids[i] = string(rune('a' + i))
+	}
+	if err := adapter.AddMany(ids, docs); err != nil {
+		t.Fatal(err)
+	}
+	return adapter
+}
+
+var testDocs = []string{
+	"the quick brown fox jumps over the lazy dog",
+	"a quick red fox",
+	"the lazy cat sleeps all day",
+	"brown bears eat fish",
+	"dogs and cats play together",
+	"the fox and the hound",
+}
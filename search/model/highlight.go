@@ -0,0 +1,166 @@
+package model
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"BMXGo/search/text_preprocessor"
+)
+
+// HighlightOptions configures the snippets returned for each hit.
+type HighlightOptions struct {
+	FragmentSize int    // Approximate snippet length in bytes, defaults to 150
+	NumFragments int    // Max snippets per hit, defaults to 3
+	PreTag       string // Defaults to <em>
+	PostTag      string // Defaults to </em>
+}
+
+// Span is a byte range [Start, End) of the document text.
+type Span struct {
+	Start int
+	End   int
+}
+
+// Snippet is a fragment of a document text with the matched words marked.
+// Start, End and Spans are offsets in the document text.
+type Snippet struct {
+	Text  string
+	Score float64
+	Start int
+	End   int
+	Spans []Span
+}
+
+func (o HighlightOptions) withDefaults() HighlightOptions {
+	if o.FragmentSize <= 0 {
+		o.FragmentSize = 150
+	}
+	if o.NumFragments <= 0 {
+		o.NumFragments = 3
+	}
+	if o.PreTag == "" && o.PostTag == "" {
+		o.PreTag, o.PostTag = "<em>", "</em>"
+	}
+	return o
+}
+
+// Highlight returns the best-scoring snippets of the document for an
+// initialized query, best first. A snippet scores the sum of weight * idf of
+// the distinct query tokens it contains.
+func (query *Query) Highlight(bmx *BMX, docID string, opts HighlightOptions) []Snippet {
+	opts = opts.withDefaults()
+	doc := bmx.Docs[docID]
+	text := doc.Text
+	matches := []text_preprocessor.Token{}
+	// The text is analysed in the language it was indexed in
+	for _, token := range bmx.TextPreprocessor.ProcessLanguageWithOffsets(text, doc.language()) {
+		if query.Tokens[token.Text] > 0 {
+			matches = append(matches, token)
+		}
+	}
+	if len(matches) == 0 {
+		return nil
+	}
+
+	candidates := []Snippet{}
+	for _, anchor := range matches {
+		// Without whitespace before the anchor (CJK, URLs), wordStart would
+		// skip past it.
+		start := min(wordStart(text, max(0, anchor.Start-opts.FragmentSize/4)), anchor.Start)
+		end := max(wordEnd(text, min(len(text), start+opts.FragmentSize)), anchor.End)
+		snippet := Snippet{Start: start, End: end}
+		seen := map[string]bool{}
+		for _, match := range matches {
+			if match.Start < start || match.End > end {
+				continue
+			}
+			if !seen[match.Text] {
+				seen[match.Text] = true
+				snippet.Score += query.Tokens[match.Text] * bmx.IDF_table[match.Text]
+			}
+			// A word yielding several tokens is marked once, and
+			// overlapping words (bigrams) are marked together.
+			if n := len(snippet.Spans); n == 0 || snippet.Spans[n-1].End <= match.Start {
+				snippet.Spans = append(snippet.Spans, Span{Start: match.Start, End: match.End})
+			} else {
+				snippet.Spans[n-1].End = max(snippet.Spans[n-1].End, match.End)
+			}
+		}
+		candidates = append(candidates, snippet)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+	snippets := []Snippet{}
+	for _, candidate := range candidates {
+		overlaps := false
+		for _, snippet := range snippets {
+			if candidate.Start < snippet.End && snippet.Start < candidate.End {
+				overlaps = true
+				break
+			}
+		}
+		if overlaps {
+			continue
+		}
+		candidate.Text = markSpans(text, candidate, opts)
+		snippets = append(snippets, candidate)
+		if len(snippets) == opts.NumFragments {
+			break
+		}
+	}
+	return snippets
+}
+
+// wordStart moves i forward to the start of a word, unless it is 0.
+func wordStart(text string, i int) int {
+	if i == 0 {
+		return 0
+	}
+	for i < len(text) && !utf8.RuneStart(text[i]) {
+		i++
+	}
+	for i < len(text) {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if unicode.IsSpace(r) {
+			return i + size
+		}
+		i += size
+	}
+	return i
+}
+
+// wordEnd moves i back to the end of a word, unless it is len(text).
+func wordEnd(text string, i int) int {
+	if i >= len(text) {
+		return len(text)
+	}
+	for i > 0 && !utf8.RuneStart(text[i]) {
+		i--
+	}
+	for j := i; j > 0; {
+		r, size := utf8.DecodeLastRuneInString(text[:j])
+		if unicode.IsSpace(r) {
+			return j - size
+		}
+		j -= size
+	}
+	return i
+}
+
+func markSpans(text string, snippet Snippet, opts HighlightOptions) string {
+	var sb strings.Builder
+	position := snippet.Start
+	for _, span := range snippet.Spans {
+		sb.WriteString(text[position:span.Start])
+		sb.WriteString(opts.PreTag)
+		sb.WriteString(text[span.Start:span.End])
+		sb.WriteString(opts.PostTag)
+		position = span.End
+	}
+	sb.WriteString(text[position:snippet.End])
+	return strings.TrimSpace(sb.String())
+}
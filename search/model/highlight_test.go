@@ -0,0 +1,79 @@
+package model
+
+import (
+	"testing"
+
+	"BMXGo/search/text_preprocessor"
+)
+
+func TestHighlight(t *testing.T) {
+	adapter := newTestAdapter(t, "The quick brown fox jumps over the lazy dog.", "Write an e-mail to foo@bar.com today.")
+	tests := []struct {
+		query string
+		opts  HighlightOptions
+		key   string
+		want  string
+	}{
+		{"fox dog", HighlightOptions{}, "a", "The quick brown <em>fox</em> jumps over the lazy <em>dog</em>."},
+		{"fox", HighlightOptions{PreTag: "[", PostTag: "]"}, "a", "The quick brown [fox] jumps over the lazy dog."},
+		{"foo@bar.com", HighlightOptions{}, "b", "Write an e-mail to <em>foo@bar.com</em> today."},
+		{"mail", HighlightOptions{}, "b", "Write an e-<em>mail</em> to foo@bar.com today."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			results := adapter.SearchWithOptions(tt.query, SearchOptions{TopK: 1, Highlight: &tt.opts})
+			if len(results.Keys) != 1 || results.Keys[0] != tt.key {
+				t.Fatalf("results = %q, want [%s]", results.Keys, tt.key)
+			}
+			if snippets := results.Highlights[0]; len(snippets) != 1 || snippets[0].Text != tt.want {
+				t.Errorf("snippets = %+v, want %q", snippets, tt.want)
+			}
+		})
+	}
+}
+
+// TestHighlightBigrams checks that the overlapping bigrams of a CJK run are
+// marked on their own characters, together when they overlap.
+func TestHighlightBigrams(t *testing.T) {
+	analyzer, err := text_preprocessor.NewAnalyzer(text_preprocessor.AnalyzerDefinition{Name: "test_cjk", Tokenizer: "cjk"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	adapter := Build("test", text_preprocessor.Config{Analyzer: analyzer})
+	if err := adapter.AddMany([]string{"a"}, []string{"東京都に住む"}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"京都", "東<em>京都</em>に住む"},
+		{"東京都", "<em>東京都</em>に住む"},
+	}
+	for _, tt := range tests {
+		results := adapter.SearchWithOptions(tt.query, SearchOptions{TopK: 1, Highlight: &HighlightOptions{}})
+		if snippets := results.Highlights[0]; len(snippets) != 1 || snippets[0].Text != tt.want {
+			t.Errorf("snippets of %s = %+v, want %q", tt.query, snippets, tt.want)
+		}
+	}
+}
+
+// TestHighlightDocumentLanguage checks that a document is highlighted in the
+// language it was indexed in rather than the detected one: analysed in
+// french, "les" would be a stopword.
+func TestHighlightDocumentLanguage(t *testing.T) {
+	config, err := text_preprocessor.NewMultilingualConfig("word", "english", "french")
+	if err != nil {
+		t.Fatal(err)
+	}
+	adapter := Build("test", *config)
+	metadata := []Metadata{{LanguageKey: KeywordAttr("english")}}
+	if err := adapter.AddManyWithMetadata([]string{"a"}, []string{"Les chats dorment"}, metadata); err != nil {
+		t.Fatal(err)
+	}
+	results := adapter.SearchWithOptions("the les", SearchOptions{TopK: 1, Highlight: &HighlightOptions{}})
+	want := "<em>Les</em> chats dorment"
+	if snippets := results.Highlights[0]; len(snippets) != 1 || snippets[0].Text != want {
+		t.Errorf("snippets = %+v, want %q", snippets, want)
+	}
+}
@@ -0,0 +1,148 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// HybridOptions configures HybridSearch. The embedded SearchOptions apply to
+// the fused results (TopK, Offset, Filter, Facets, Highlight) and to the
+// BMX side.
+type HybridOptions struct {
+	SearchOptions
+	Fusion      FusionMethod
+	CandidateK  int     // Candidates per retriever, defaults to max(50, Offset+TopK)
+	RRFK        float64 // RRF constant, defaults to 60
+	DenseWeight float64 // Weight of the dense scores with score-based fusion, defaults to 0.5
+}
+
+// SetEmbedder enables dense retrieval. Documents added afterwards are
+// embedded with embedder and stored in index, a BruteForceIndex when nil.
+func (adapter *BMXAdapter) SetEmbedder(embedder Embedder, index VectorIndex) {
+	if index == nil {
+		index = NewBruteForceIndex()
+	}
+	adapter.bmx.Embedder = embedder
+	adapter.bmx.Vectors = index
+}
+
+// AddVectors stores precomputed vectors for indexed documents. The ids and
+// vectors are validated first, so an error leaves the vectors unchanged.
+func (adapter *BMXAdapter) AddVectors(ids []string, vectors [][]float32) error {
+	if len(vectors) != len(ids) {
+		return fmt.Errorf("%d vectors for %d documents", len(vectors), len(ids))
+	}
+	dims := 0
+	for _, doc := range adapter.bmx.Docs {
+		if len(doc.Vector) > 0 {
+			dims = len(doc.Vector)
+			break
+		}
+	}
+	for i, id := range ids {
+		if _, ok := adapter.bmx.Docs[id]; !ok {
+			return errors.New("document " + id + " not found")
+		}
+		if dims == 0 {
+			dims = len(vectors[i])
+		}
+		if len(vectors[i]) == 0 || len(vectors[i]) != dims {
+			return fmt.Errorf("vector of %s has %d dimensions, expected %d", id, len(vectors[i]), dims)
+		}
+	}
+
+	if adapter.bmx.Vectors == nil {
+		adapter.bmx.Vectors = NewBruteForceIndex()
+	}
+	for i, id := range ids {
+		if err := adapter.bmx.Vectors.Add(id, vectors[i]); err != nil {
+			return err
+		}
+		doc := adapter.bmx.Docs[id]
+		doc.Vector = vectors[i]
+		adapter.bmx.Docs[id] = doc
+	}
+	return nil
+}
+
+// embedTexts embeds the texts of documents about to be added, nil when no
+// embedder is set. Documents are embedded before they are indexed, so that
+// an embedding failure leaves the index unchanged.
+func (adapter *BMXAdapter) embedTexts(texts []string) ([][]float32, error) {
+	if adapter.bmx.Embedder == nil || len(texts) == 0 {
+		return nil, nil
+	}
+	vectors, err := adapter.bmx.Embedder.Embed(context.Background(), texts)
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) != len(texts) {
+		return nil, fmt.Errorf("embedder returned %d vectors for %d texts", len(vectors), len(texts))
+	}
+	for _, vector := range vectors {
+		if len(vector) == 0 || len(vector) != len(vectors[0]) {
+			return nil, fmt.Errorf("embedder returned vectors of inconsistent dimensions")
+		}
+	}
+	return vectors, nil
+}
+
+// storeVectors stores the vectors returned by embedTexts for indexed documents.
+func (adapter *BMXAdapter) storeVectors(ids []string, vectors [][]float32) error {
+	if vectors == nil {
+		return nil
+	}
+	return adapter.AddVectors(ids, vectors)
+}
+
+// HybridSearch runs BMX and the dense vector search and fuses their rankings.
+func (adapter *BMXAdapter) HybridSearch(ctx context.Context, query string, opts HybridOptions) (SearchResults, error) {
+	if adapter.bmx.Embedder == nil || adapter.bmx.Vectors == nil {
+		return SearchResults{}, errors.New("hybrid search needs an embedder, see SetEmbedder")
+	}
+	candidateK := opts.CandidateK
+	if candidateK <= 0 {
+		candidateK = max(50, opts.Offset+opts.TopK)
+	}
+	denseWeight := opts.DenseWeight
+	if denseWeight <= 0 || denseWeight > 1 {
+		denseWeight = 0.5
+	}
+
+	q := opts.query(query)
+	q.Initialize(adapter.bmx)
+	lexical := topResults(&q, adapter.bmx, SearchOptions{TopK: candidateK, Normalization: NormalizeRaw, MinScore: 1e-12})
+
+	vectors, err := adapter.bmx.Embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return SearchResults{}, err
+	}
+	if len(vectors) != 1 {
+		return SearchResults{}, fmt.Errorf("embedder returned %d vectors for the query", len(vectors))
+	}
+	dense := SearchResults{}
+	allow := func(key string) bool {
+		_, ok := adapter.bmx.Docs[key]
+		return ok && q.allows(adapter.bmx, key)
+	}
+	for _, hit := range adapter.bmx.Vectors.Search(vectors[0], candidateK, allow) {
+		dense.Keys = append(dense.Keys, hit.Key)
+		dense.Scores = append(dense.Scores, hit.Score)
+	}
+
+	weights := []float64{1, 1}
+	if opts.Fusion != FusionRRF {
+		weights = []float64{1 - denseWeight, denseWeight}
+	}
+	results := Fuse([]SearchResults{lexical, dense}, FuseOptions{
+		Method: opts.Fusion, Weights: weights, RRFK: opts.RRFK, Offset: opts.Offset, TopK: opts.TopK,
+	})
+	results.Facets = q.Facets(adapter.bmx, opts.SearchOptions)
+	if opts.Highlight != nil {
+		for _, key := range results.Keys {
+			results.Highlights = append(results.Highlights, q.Highlight(adapter.bmx, key, *opts.Highlight))
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,149 @@
+package model
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"BMXGo/search/text_preprocessor"
+)
+
+// stubEmbedder returns err, or count zero vectors per call.
+type stubEmbedder struct {
+	err   error
+	count int
+}
+
+func (e stubEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if e.err != nil {
+		return nil, e.err
+	}
+	vectors := make([][]float32, e.count)
+	for i := range vectors {
+		vectors[i] = make([]float32, 4)
+	}
+	return vectors, nil
+}
+
+func newHybridAdapter(t *testing.T, embedder Embedder, index VectorIndex) BMXAdapter {
+	t.Helper()
+	config, err := text_preprocessor.NewAnalyzerConfig("simple", "english")
+	if err != nil {
+		t.Fatal(err)
+	}
+	adapter := Build("test", *config)
+	adapter.SetEmbedder(embedder, index)
+	return adapter
+}
+
+func TestHybridSearch(t *testing.T) {
+	for _, index := range []VectorIndex{NewBruteForceIndex(), NewHNSWIndex()} {
+		adapter := newHybridAdapter(t, FakeEmbedder{}, index)
+		if err := adapter.AddMany([]string{"a", "b", "c", "d", "e", "f"}, testDocs); err != nil {
+			t.Fatal(err)
+		}
+		if index.Len() != len(testDocs) {
+			t.Errorf("%d vectors stored, want %d", index.Len(), len(testDocs))
+		}
+		for _, fusion := range []FusionMethod{FusionRRF, FusionWeighted, FusionCombMNZ} {
+			results, err := adapter.HybridSearch(context.Background(), "lazy cat", HybridOptions{
+				SearchOptions: SearchOptions{TopK: 3},
+				Fusion:        fusion,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results.Keys) != 3 || results.Keys[0] != "c" {
+				t.Errorf("HybridSearch(lazy cat) with fusion %d = %v, want c first of 3", fusion, results.Keys)
+			}
+		}
+	}
+}
+
+func TestHybridSearchWithoutQueryVector(t *testing.T) {
+	adapter := newHybridAdapter(t, FakeEmbedder{Dimensions: 4}, nil)
+	if err := adapter.AddMany([]string{"a", "b"}, []string{"a quick red fox", "a lazy cat"}); err != nil {
+		t.Fatal(err)
+	}
+	adapter.bmx.Embedder = stubEmbedder{count: 0}
+	if _, err := adapter.HybridSearch(context.Background(), "fox", HybridOptions{}); err == nil {
+		t.Error("HybridSearch with no query vector succeeded, want an error")
+	}
+}
+
+// TestEmbeddingFailure checks that documents are not indexed when they
+// cannot be embedded, so the lexical and vector indexes stay in sync.
+func TestEmbeddingFailure(t *testing.T) {
+	embedders := []struct {
+		name     string
+		embedder Embedder
+	}{
+		{"error", stubEmbedder{err: errors.New("embedding service down")}},
+		{"no vectors", stubEmbedder{count: 0}},
+		{"too few vectors", stubEmbedder{count: 1}},
+	}
+	adds := []struct {
+		name string
+		add  func(adapter *BMXAdapter) error
+	}{
+		{"AddMany", func(adapter *BMXAdapter) error {
+			return adapter.AddMany([]string{"x", "y"}, []string{"a brown bear", "a red fox"})
+		}},
+		{"AddManyWithMetadata", func(adapter *BMXAdapter) error {
+			return adapter.AddManyWithMetadata([]string{"x", "y"}, []string{"a brown bear", "a red fox"}, nil)
+		}},
+		{"AddDocuments", func(adapter *BMXAdapter) error {
+			return adapter.AddDocuments([]FieldedDocument{
+				{ID: "x", Fields: map[string]string{"title": "bear", "body": "a brown bear"}},
+				{ID: "y", Fields: map[string]string{"title": "fox", "body": "a red fox"}},
+			})
+		}},
+	}
+	for _, embedder := range embedders {
+		for _, add := range adds {
+			t.Run(embedder.name+"/"+add.name, func(t *testing.T) {
+				adapter := newHybridAdapter(t, FakeEmbedder{Dimensions: 4}, nil)
+				if err := adapter.AddMany([]string{"a", "b"}, []string{"a quick red fox", "a lazy cat"}); err != nil {
+					t.Fatal(err)
+				}
+				adapter.bmx.Embedder = embedder.embedder
+				if err := add.add(&adapter); err == nil {
+					t.Fatal("adding documents succeeded, want an error")
+				}
+				if len(adapter.bmx.Docs) != 2 || adapter.bmx.Vectors.Len() != 2 {
+					t.Errorf("%d documents and %d vectors after a failed add, want 2 and 2", len(adapter.bmx.Docs), adapter.bmx.Vectors.Len())
+				}
+			})
+		}
+	}
+}
+
+func TestAddVectorsValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		ids     []string
+		vectors [][]float32
+	}{
+		{"fewer vectors", []string{"a", "b"}, [][]float32{{1, 0, 0, 0}}},
+		{"more vectors", []string{"a"}, [][]float32{{1, 0, 0, 0}, {0, 1, 0, 0}}},
+		{"unknown document", []string{"a", "missing"}, [][]float32{{1, 0, 0, 0}, {0, 1, 0, 0}}},
+		{"empty vector", []string{"a", "b"}, [][]float32{{1, 0, 0, 0}, {}}},
+		{"dimensions of the index", []string{"a"}, [][]float32{{1, 0}}},
+		{"inconsistent dimensions", []string{"a", "b"}, [][]float32{{1, 0, 0, 0}, {1, 0}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adapter := newHybridAdapter(t, FakeEmbedder{Dimensions: 4}, nil)
+			if err := adapter.AddMany([]string{"a", "b"}, []string{"a quick red fox", "a lazy cat"}); err != nil {
+				t.Fatal(err)
+			}
+			before := adapter.bmx.Docs["a"].Vector
+			if err := adapter.AddVectors(tt.ids, tt.vectors); err == nil {
+				t.Fatal("AddVectors succeeded, want an error")
+			}
+			if got := adapter.bmx.Docs["a"].Vector; len(got) != len(before) || got[0] != before[0] {
+				t.Errorf("vector of a = %v after a failed AddVectors, want %v", got, before)
+			}
+		})
+	}
+}
@@ -30,11 +30,12 @@ type ConvMessage struct {
 }
 
 type LLMClient struct {
-	apiKey     string
-	baseURL    string
-	httpClient *http.Client
-	appName    string
-	appURL     string
+	apiKey        string
+	baseURL       string
+	embeddingsURL string
+	httpClient    *http.Client
+	appName       string
+	appURL        string
 }
 
 type ClientConfig struct {
@@ -46,6 +47,7 @@ type ClientConfig struct {
 	Provider       string
 	ResourceName   string
 	DeploymentName string
+	EmbeddingsURL  string // Overrides the provider's embeddings endpoint, e.g. for a local OpenAI-compatible server
 }
 
 func HtmlToMarkdown(htmlContent string, addIDs bool) string {
@@ -164,31 +166,40 @@ func HtmlToMarkdown(htmlContent string, addIDs bool) string {
 }
 
 func NewLLMClient(config ClientConfig) *LLMClient {
+	embeddingsURL := ""
 	switch config.Provider {
 	case "azure":
 		config.BaseURL = fmt.Sprintf("https://%s/openai/deployments/%s/chat/completions?api-version=2023-12-01-preview", os.Getenv("AZURE_OAI_DOMAIN"), config.DeploymentName)
+		embeddingsURL = fmt.Sprintf("https://%s/openai/deployments/%s/embeddings?api-version=2023-05-15", os.Getenv("AZURE_OAI_DOMAIN"), config.DeploymentName)
 		config.APIKey = os.Getenv("AZURE_API_KEY")
 	case "openai":
 		config.BaseURL = "https://api.openai.com/v1/chat/completions"
+		embeddingsURL = "https://api.openai.com/v1/embeddings"
 		config.APIKey = os.Getenv("OPENAI_API_KEY")
 	case "openrouter":
 		config.BaseURL = "https://openrouter.ai/api/v1/chat/completions"
+		embeddingsURL = "https://openrouter.ai/api/v1/embeddings"
 		config.APIKey = os.Getenv("OPENROUTER_API_KEY")
 	default:
 		config.BaseURL = "https://openrouter.ai/api/v1/chat/completions"
+		embeddingsURL = "https://openrouter.ai/api/v1/embeddings"
 		config.APIKey = os.Getenv("OPENROUTER_API_KEY")
 	}
+	if config.EmbeddingsURL != "" {
+		embeddingsURL = config.EmbeddingsURL
+	}
 
 	if config.HTTPClient == nil {
 		config.HTTPClient = &http.Client{Timeout: 120 * time.Second}
 	}
 
 	return &LLMClient{
-		apiKey:     config.APIKey,
-		baseURL:    config.BaseURL,
-		httpClient: config.HTTPClient,
-		appName:    config.AppName,
-		appURL:     config.AppURL,
+		apiKey:        config.APIKey,
+		baseURL:       config.BaseURL,
+		embeddingsURL: embeddingsURL,
+		httpClient:    config.HTTPClient,
+		appName:       config.AppName,
+		appURL:        config.AppURL,
 	}
 }
 
@@ -197,13 +208,18 @@ type ChatCompletionRequest struct {
 	Messages    []ConvMessage `json:"messages"`
 	Stream      bool          `json:"stream"`
 	Temperature float32       `json:"temperature"`
-	MaxTokens   int           `json:"max_tokens"`
+	MaxTokens   int           `json:"max_tokens"` // Defaults to 4000
 }
 
 func (c *LLMClient) Completion(ctx context.Context, request ChatCompletionRequest) (<-chan string, <-chan error) {
 	responseChan := make(chan string)
 	errChan := make(chan error)
 
+	maxTokens := request.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = 4000
+	}
+
 	go func() {
 		defer close(responseChan)
 		defer close(errChan)
@@ -219,7 +235,7 @@ func (c *LLMClient) Completion(ctx context.Context, request ChatCompletionReques
 					"messages":    request.Messages,
 					"stream":      request.Stream,
 					"temperature": request.Temperature,
-					"max_tokens":  4000,
+					"max_tokens":  maxTokens,
 				}
 
 				jsonBody, err := json.Marshal(requestBody)
@@ -325,3 +341,88 @@ func (c *LLMClient) handleNonStreamingResponse(body io.ReadCloser, responseChan
 		errChan <- fmt.Errorf("no content in response")
 	}
 }
+
+// Embeddings calls the OpenAI-compatible embeddings endpoint of the provider
+// and returns one vector per input, in order.
+func (c *LLMClient) Embeddings(ctx context.Context, model string, inputs []string) ([][]float32, error) {
+	jsonBody, err := json.Marshal(map[string]interface{}{
+		"model": model,
+		"input": inputs,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling embeddings request for model %s: %v", model, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", c.embeddingsURL, bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("error creating embeddings request for model %s: %v", model, err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	req.Header.Set("api-key", c.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error making embeddings request for model %s: %v", model, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("model %s failed with status code: %d\nResponse body: %s", model, resp.StatusCode, string(body))
+	}
+
+	var response struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, fmt.Errorf("error decoding embeddings response: %v", err)
+	}
+	if len(response.Data) != len(inputs) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(inputs), len(response.Data))
+	}
+	vectors := make([][]float32, len(inputs))
+	for _, data := range response.Data {
+		if data.Index < 0 || data.Index >= len(inputs) {
+			return nil, fmt.Errorf("embedding index %d out of range", data.Index)
+		}
+		vectors[data.Index] = data.Embedding
+	}
+	return vectors, nil
+}
+
+// CompletionText runs a non-streaming completion and returns the whole
+// response. Unlike Completion it drains both channels, so later models are
+// tried when one fails and the worker goroutine always exits.
+func (c *LLMClient) CompletionText(ctx context.Context, request ChatCompletionRequest) (string, error) {
+	request.Stream = false
+	respChan, errChan := c.Completion(ctx, request)
+	var response strings.Builder
+	var lastErr error
+	for respChan != nil || errChan != nil {
+		select {
+		case text, ok := <-respChan:
+			if !ok {
+				respChan = nil
+				continue
+			}
+			response.WriteString(text)
+		case err, ok := <-errChan:
+			if !ok {
+				errChan = nil
+				continue
+			}
+			lastErr = err
+		}
+	}
+	if response.Len() > 0 {
+		return response.String(), nil
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("empty response")
+	}
+	return "", lastErr
+}
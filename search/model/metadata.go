@@ -0,0 +1,194 @@
+package model
+
+import (
+	"sort"
+	"time"
+)
+
+type AttributeKind int
+
+const (
+	KeywordAttribute AttributeKind = iota
+	NumericAttribute
+	DateAttribute
+	BoolAttribute
+)
+
+// Attribute is a typed metadata value. Keyword attributes may hold several
+// values (tags, categories).
+type Attribute struct {
+	Kind     AttributeKind
+	Keywords []string
+	Number   float64
+	Date     time.Time
+	Bool     bool
+}
+
+// Metadata maps attribute names to values.
+type Metadata map[string]Attribute
+
+func KeywordAttr(values ...string) Attribute {
+	return Attribute{Kind: KeywordAttribute, Keywords: values}
+}
+
+func NumericAttr(value float64) Attribute {
+	return Attribute{Kind: NumericAttribute, Number: value}
+}
+
+func DateAttr(value time.Time) Attribute {
+	return Attribute{Kind: DateAttribute, Date: value}
+}
+
+func BoolAttr(value bool) Attribute {
+	return Attribute{Kind: BoolAttribute, Bool: value}
+}
+
+// sortValue is the value used by the sorted numeric and date indexes.
+// Dates are indexed in milliseconds since the epoch.
+func (a Attribute) sortValue() float64 {
+	if a.Kind == DateAttribute {
+		return float64(a.Date.UnixMilli())
+	}
+	return a.Number
+}
+
+type sortedEntry struct {
+	value   float64
+	ordinal int
+}
+
+// MetadataIndex holds one bitmap per keyword and boolean value, and sorted
+// (value, ordinal) columns for numeric and date attributes.
+type MetadataIndex struct {
+	keywords map[string]map[string]*Bitmap
+	bools    map[string]map[bool]*Bitmap
+	sorted   map[string][]sortedEntry
+	present  map[string]*Bitmap
+}
+
+// Ordinals_fill gives each document a dense ordinal used by the bitmaps.
+// Ordinals are stable across additions.
+func (bmx *BMX) Ordinals_fill() {
+	if bmx.Ordinals == nil {
+		bmx.Ordinals = make(map[string]int, len(bmx.Docs))
+	}
+	newKeys := []string{}
+	for doc_key := range bmx.Docs {
+		if _, ok := bmx.Ordinals[doc_key]; !ok {
+			newKeys = append(newKeys, doc_key)
+		}
+	}
+	sort.Strings(newKeys)
+	for _, doc_key := range newKeys {
+		bmx.Ordinals[doc_key] = len(bmx.DocIDs)
+		bmx.DocIDs = append(bmx.DocIDs, doc_key)
+	}
+}
+
+func (bmx *BMX) Metadata_index_fill() {
+	index := MetadataIndex{
+		keywords: map[string]map[string]*Bitmap{},
+		bools:    map[string]map[bool]*Bitmap{},
+		sorted:   map[string][]sortedEntry{},
+		present:  map[string]*Bitmap{},
+	}
+	size := len(bmx.DocIDs)
+	bitmap := func(m map[string]*Bitmap, key string) *Bitmap {
+		if _, ok := m[key]; !ok {
+			m[key] = NewBitmap(size)
+		}
+		return m[key]
+	}
+	for doc_key, doc := range bmx.Docs {
+		ordinal := bmx.Ordinals[doc_key]
+		for name, attr := range doc.Metadata {
+			bitmap(index.present, name).Add(ordinal)
+			switch attr.Kind {
+			case KeywordAttribute:
+				if _, ok := index.keywords[name]; !ok {
+					index.keywords[name] = map[string]*Bitmap{}
+				}
+				for _, value := range attr.Keywords {
+					bitmap(index.keywords[name], value).Add(ordinal)
+				}
+			case BoolAttribute:
+				if _, ok := index.bools[name]; !ok {
+					index.bools[name] = map[bool]*Bitmap{true: NewBitmap(size), false: NewBitmap(size)}
+				}
+				index.bools[name][attr.Bool].Add(ordinal)
+			case NumericAttribute, DateAttribute:
+				index.sorted[name] = append(index.sorted[name], sortedEntry{value: attr.sortValue(), ordinal: ordinal})
+			}
+		}
+	}
+	for name := range index.sorted {
+		entries := index.sorted[name]
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].value < entries[j].value
+		})
+	}
+	bmx.MetadataIndex = index
+}
+
+// rangeBitmap returns the documents whose attribute lies in [min, max].
+func (index MetadataIndex) rangeBitmap(name string, min, max float64, size int) *Bitmap {
+	result := NewBitmap(size)
+	entries := index.sorted[name]
+	start := sort.Search(len(entries), func(i int) bool { return entries[i].value >= min })
+	for i := start; i < len(entries) && entries[i].value <= max; i++ {
+		result.Add(entries[i].ordinal)
+	}
+	return result
+}
+
+// LanguageKey is the keyword attribute holding the language of documents
+// when the text preprocessor detects languages.
+const LanguageKey = "language"
+
+// documentLanguage returns the language to analyse a document with: its
+// LanguageKey attribute when set, else the detected language, which is then
+// recorded in a copy of metadata.
+func (bmx *BMX) documentLanguage(text string, metadata *Metadata) string {
+	if attr, ok := (*metadata)[LanguageKey]; ok && len(attr.Keywords) > 0 {
+		return attr.Keywords[0]
+	}
+	lang := bmx.TextPreprocessor.DetectLanguage(text)
+	if lang == "" {
+		return ""
+	}
+	withLanguage := Metadata{LanguageKey: KeywordAttr(lang)}
+	for name, attr := range *metadata {
+		withLanguage[name] = attr
+	}
+	*metadata = withLanguage
+	return lang
+}
+
+// language returns the language the document was indexed in, "" when it
+// was analysed with the base configuration.
+func (doc Document) language() string {
+	if attr, ok := doc.Metadata[LanguageKey]; ok && len(attr.Keywords) > 0 {
+		return attr.Keywords[0]
+	}
+	return ""
+}
+
+// AddManyWithMetadata indexes documents like AddMany and stores metadata[i]
+// with docs[i].
+func (adapter *BMXAdapter) AddManyWithMetadata(ids []string, docs []string, metadata []Metadata) error {
+	vectors, err := adapter.embedTexts(docs)
+	if err != nil {
+		return err
+	}
+	for i, doc := range docs {
+		document := Document{Text: doc}
+		if i < len(metadata) {
+			document.Metadata = metadata[i]
+		}
+		lang := adapter.bmx.documentLanguage(doc, &document.Metadata)
+		document.Tokens = adapter.bmx.TextPreprocessor.ProcessLanguage(doc, lang)
+		adapter.bmx.Docs[ids[i]] = document
+	}
+	adapter.bmx.FillTables()
+	return adapter.storeVectors(ids[:len(docs)], vectors)
+}
@@ -2,16 +2,19 @@ package model
 
 import (
 	"math"
-	"sort"
 
 	"BMXGo/search/text_preprocessor"
 )
 
 // Define the parameters and types
 type Document struct {
-	Text    string
-	Tokens  []string
-	F_table map[string]int
+	Text      string
+	Tokens    []string
+	F_table   map[string]int
+	Fields    map[string]Field
+	Metadata  Metadata
+	Positions map[string][]int // Token positions in Tokens, when the positional index is enabled
+	Vector    []float32        // Dense embedding, when dense retrieval is enabled
 }
 
 type Query struct {
@@ -25,6 +28,18 @@ type Query struct {
 	NormalizedScoreTable map[string]float64
 	AugmentedQueries     []string
 	AugmentedWeights     []float64
+	AugmentedTokens      [][]string // Pre-analysed tokens used instead of processing AugmentedQueries[i], when set
+	OriginalWeight       float64    // Weight of each token of the original query, defaults to 1
+	Scorer               Scorer
+	Overrides            ParamOverrides
+	FieldBoosts          map[string]float64
+	Filter               Filter
+	Candidates           *Bitmap   // Ordinals allowed in the results, nil for all
+	Node                 QueryNode // Parsed boolean query, replaces the tokens of Text when set
+	ProximityBoost       float64
+	Expansion            ExpansionOptions
+	orderedTokens        []string
+	language             string // Detected once on the full query unless preset, "" when language detection is off
 }
 
 type Parameters struct {
@@ -34,6 +49,31 @@ type Parameters struct {
 	N     int
 }
 
+// ParamOverrides pins BMX parameters instead of deriving them from the corpus,
+// and the k1 and b of the BM25 scorers instead of using the scorer's own.
+// Nil fields keep the auto-derived or scorer value.
+type ParamOverrides struct {
+	Alpha *float64
+	Beta  *float64
+	K1    *float64
+	B     *float64
+}
+
+// FixedParams returns overrides pinning both Alpha and Beta.
+func FixedParams(alpha, beta float64) ParamOverrides {
+	return ParamOverrides{Alpha: &alpha, Beta: &beta}
+}
+
+func (o ParamOverrides) apply(params Parameters) Parameters {
+	if o.Alpha != nil {
+		params.Alpha = *o.Alpha
+	}
+	if o.Beta != nil {
+		params.Beta = *o.Beta
+	}
+	return params
+}
+
 type BMX struct {
 	Docs             map[string]Document
 	Params           Parameters
@@ -41,6 +81,17 @@ type BMX struct {
 	NumAppearances   map[string][]string
 	IDF_table        map[string]float64
 	E_tilde_table    map[string]float64
+	FieldStats       map[string]FieldStats
+	Ordinals         map[string]int
+	DocIDs           []string
+	MetadataIndex    MetadataIndex
+	StorePositions   bool
+	Terms            *TermDictionary
+	Embedder         Embedder
+	Vectors          VectorIndex
+	Scorer           Scorer
+	Overrides        ParamOverrides
+	autoParams       Parameters
 }
 
 func (bmx *BMX) InitializeTextPreprocessor(config *text_preprocessor.Config) error {
@@ -59,12 +110,42 @@ func (bmx *BMX) SetParams() {
 	Alpha := max(min(1.5, Avgdl/100), 0.5)
 	Beta := 1 / math.Log(1+float64(N))
 
-	bmx.Params = Parameters{
+	bmx.autoParams = Parameters{
 		Alpha: Alpha,
 		Beta:  Beta,
 		Avgdl: Avgdl,
 		N:     N,
 	}
+	bmx.Params = bmx.Overrides.apply(bmx.autoParams)
+}
+
+// FillTables recomputes the parameters and every index table from bmx.Docs.
+func (bmx *BMX) FillTables() {
+	bmx.Ordinals_fill()
+	// fmt.Println("Setting params")
+	// start := time.Now()
+	bmx.SetParams()
+	// fmt.Println("Parameters set, total time:", time.Since(start))
+	// start = time.Now()
+	// fmt.Println("Filling F table")
+	bmx.F_table_fill()
+	// fmt.Println("F table filled, total time:", time.Since(start))
+	bmx.Positions_fill()
+	// start = time.Now()
+	// fmt.Println("Calculating number of appearances")
+	bmx.NumAppearancesCalc()
+	// fmt.Println("Number of appearances calculated, total time:", time.Since(start))
+	// start = time.Now()
+	// fmt.Println("Filling IDF table")
+	bmx.IDF_table_fill()
+	// fmt.Println("IDF table filled, total time:", time.Since(start))
+	bmx.Term_dictionary_fill()
+	// start = time.Now()
+	// fmt.Println("Filling E_tilde table")
+	bmx.E_tilde_table_fill()
+	// fmt.Println("E_tilde table filled, total time:", time.Since(start))
+	bmx.Field_stats_fill()
+	bmx.Metadata_index_fill()
 }
 
 func (bmx *BMX) F_table_fill() {
@@ -78,6 +159,13 @@ func (bmx *BMX) F_table_fill() {
 				doc.F_table[token]++
 			}
 		}
+		for name, field := range doc.Fields {
+			field.F_table = make(map[string]int)
+			for _, token := range field.Tokens {
+				field.F_table[token]++
+			}
+			doc.Fields[name] = field
+		}
 		bmx.Docs[doc_key] = doc
 	}
 }
@@ -125,6 +213,10 @@ func (query *Query) SetEntropy(bmx *BMX) {
 		}
 		query.avgEntropy += bmx.E_tilde_table[qi] * query.Tokens[qi]
 	}
+	if query.TotalWeight == 0 || query.max_E_tilde == 0 {
+		query.avgEntropy = 0.0
+		return
+	}
 	query.avgEntropy /= query.TotalWeight
 	query.avgEntropy /= query.max_E_tilde
 }
@@ -132,7 +224,10 @@ func (query *Query) SetEntropy(bmx *BMX) {
 // Function to calculate S(Q, D)
 func (query *Query) S_table_fill(bmx *BMX) {
 	query.S_table = make(map[string]float64, len(bmx.Docs))
-	invTotalWeight := 1.0 / query.TotalWeight
+	invTotalWeight := 0.0
+	if query.TotalWeight > 0 {
+		invTotalWeight = 1.0 / query.TotalWeight
+	}
 
 	for doc_key := range bmx.Docs {
 		query.S_table[doc_key] = 0.0
@@ -148,49 +243,102 @@ func (query *Query) S_table_fill(bmx *BMX) {
 	}
 }
 
-// Function to calculate the score
-func (query *Query) Score_table_fill(bmx *BMX) {
-	query.ScoreTable = make(map[string]float64, len(bmx.Docs))
-	for doc_key := range bmx.Docs {
-		query.ScoreTable[doc_key] = 0.0
-	}
-	invE_tilde := 1.0 / query.max_E_tilde
-	invAvgdl := 1.0 / bmx.Params.Avgdl
-	alpha1 := bmx.Params.Alpha + 1.0
-	for qi := range query.Tokens {
-		idf := bmx.IDF_table[qi]
-		e := bmx.E_tilde_table[qi] * invE_tilde
-		alphaAverageEntropy := bmx.Params.Alpha * query.avgEntropy
-		betaE := bmx.Params.Beta * e
-		for _, doc_key := range bmx.NumAppearances[qi] {
-			f := bmx.Docs[doc_key].F_table[qi]
-			s := query.S_table[doc_key]
-			query.ScoreTable[doc_key] += query.Tokens[qi] * (idf*(float64(f)*alpha1/(float64(f)+bmx.Params.Alpha*(float64(len(bmx.Docs[doc_key].Tokens))*invAvgdl)+alphaAverageEntropy)) + betaE*s)
+// params returns the index parameters with the query's overrides applied.
+func (query *Query) params(bmx *BMX) Parameters {
+	return query.Overrides.apply(bmx.Params)
+}
+
+// bm25Params returns k1 and b with the index's then the query's overrides applied.
+func (query *Query) bm25Params(bmx *BMX, k1, b float64) (float64, float64) {
+	for _, o := range []ParamOverrides{bmx.Overrides, query.Overrides} {
+		if o.K1 != nil {
+			k1 = *o.K1
+		}
+		if o.B != nil {
+			b = *o.B
 		}
 	}
+	return k1, b
+}
+
+// Function to calculate the score
+func (query *Query) Score_table_fill(bmx *BMX) {
+	query.ScoreTable = query.scorer(bmx).Score(query, bmx)
+	query.Proximity_boost_fill(bmx)
 }
 
 func (query *Query) NormalizedScore_table_fill(bmx *BMX) {
 	query.NormalizedScoreTable = map[string]float64{}
-	invMaxScore := 1 / (query.TotalWeight * (math.Log(1+float64(float64(bmx.Params.N)-0.5)/1.5) + 1.0))
+	if query.TotalWeight == 0 {
+		for key := range bmx.Docs {
+			query.NormalizedScoreTable[key] = 0.0
+		}
+		return
+	}
+	var maxScore float64
+	if scorer, ok := query.scorer(bmx).(MaxScorer); ok {
+		maxScore = scorer.MaxScore(query, bmx)
+	} else {
+		maxScore = BMXScorer{}.MaxScore(query, bmx)
+	}
+	invMaxScore := 1 / maxScore
 	for key := range bmx.Docs {
 		query.NormalizedScoreTable[key] = query.ScoreTable[key] * invMaxScore
 	}
 }
 
+// process analyses text in the language of the query, so that its clauses
+// and augmented queries, too short to be detected reliably on their own,
+// are analysed alike.
+func (query *Query) process(bmx *BMX, text string) []string {
+	return bmx.TextPreprocessor.ProcessLanguage(text, query.language)
+}
+
+// detectionText returns the text the language of the query is detected on.
+func (query *Query) detectionText() string {
+	if query.Node != nil {
+		return nodeText(query.Node)
+	}
+	return query.Text
+}
+
 func (query *Query) Initialize(bmx *BMX) {
-	tokens := bmx.TextPreprocessor.Process(query.Text)
 	query.Tokens = make(map[string]float64)
-	for _, token := range tokens {
-		if _, ok := query.Tokens[token]; !ok {
-			query.Tokens[token] = 1.0
-		} else {
-			query.Tokens[token] += 1.0
+	weight := query.OriginalWeight
+	if weight <= 0 {
+		weight = 1.0
+	}
+	if query.language == "" {
+		query.language = bmx.TextPreprocessor.DetectLanguage(query.detectionText())
+	}
+	if query.Node != nil {
+		query.Candidates = query.compile(bmx, query.Node, weight, false)
+	} else {
+		tokens := query.process(bmx, query.Text)
+		query.orderedTokens = tokens
+		for _, token := range tokens {
+			if query.Expansion.Fuzziness > 0 || query.Expansion.Prefix {
+				for term, factor := range query.expandToken(bmx, token) {
+					query.Tokens[term] += weight * factor
+					query.TotalWeight += weight * factor
+				}
+				continue
+			}
+			if _, ok := query.Tokens[token]; !ok {
+				query.Tokens[token] = weight
+			} else {
+				query.Tokens[token] += weight
+			}
+			query.TotalWeight += weight
 		}
-		query.TotalWeight += 1.0
 	}
 	for i := range query.AugmentedQueries {
-		tokens := bmx.TextPreprocessor.Process(query.AugmentedQueries[i])
+		var tokens []string
+		if i < len(query.AugmentedTokens) && query.AugmentedTokens[i] != nil {
+			tokens = query.AugmentedTokens[i]
+		} else {
+			tokens = query.process(bmx, query.AugmentedQueries[i])
+		}
 		for _, token := range tokens {
 			if _, ok := query.Tokens[token]; !ok {
 				query.Tokens[token] = query.AugmentedWeights[i]
@@ -216,18 +364,26 @@ func (query *Query) Initialize(bmx *BMX) {
 	// start = time.Now()
 	query.NormalizedScore_table_fill(bmx)
 	// fmt.Println("Normalized score table filled, total time:", time.Since(start))
+	if query.Filter != nil {
+		if query.Candidates == nil {
+			query.Candidates = query.Filter.Bitmap(bmx)
+		} else {
+			query.Candidates.And(query.Filter.Bitmap(bmx))
+		}
+	}
 }
 
+// allows reports whether the document may appear in the results.
+func (query *Query) allows(bmx *BMX, doc_key string) bool {
+	return query.Candidates == nil || query.Candidates.Contains(bmx.Ordinals[doc_key])
+}
+
+// Rank returns the topK documents, ties broken by doc id.
 func (query *Query) Rank(topK int) []string {
-	// Create a slice of indices
-	topKeys := make([]string, len(query.ScoreTable))
-	for key := range query.ScoreTable {
-		topKeys = append(topKeys, key)
+	hits := query.ranked(topK, nil, nil)
+	topKeys := make([]string, len(hits))
+	for i, hit := range hits {
+		topKeys[i] = hit.Key
 	}
-
-	// Sort the indices based on the normalizedScoreTable in descending order
-	sort.Slice(topKeys, func(i, j int) bool {
-		return query.ScoreTable[topKeys[i]] > query.ScoreTable[topKeys[j]]
-	})
-	return topKeys[:topK]
+	return topKeys
 }
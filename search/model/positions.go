@@ -0,0 +1,232 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// PhraseNode matches documents containing its tokens next to each other in
+// order or, with a positive Slop, all within a window of len(tokens)+Slop
+// positions in any order. It requires the positional index.
+type PhraseNode struct {
+	Text  string
+	Slop  int
+	Boost float64
+}
+
+func (n PhraseNode) String() string {
+	s := fmt.Sprintf("%q", n.Text)
+	if n.Slop > 0 {
+		s += fmt.Sprintf("~%d", n.Slop)
+	}
+	if n.Boost != 1 {
+		s += fmt.Sprintf("^%g", n.Boost)
+	}
+	return s
+}
+
+// EnablePositionalIndex stores token positions with the postings, which
+// phrase queries and the proximity boost need. Existing documents are
+// re-indexed.
+func (adapter *BMXAdapter) EnablePositionalIndex() {
+	adapter.bmx.StorePositions = true
+	if len(adapter.bmx.Docs) > 0 {
+		adapter.bmx.FillTables()
+	}
+}
+
+// fieldPositionGap separates the positions of consecutive fields of a
+// document, so phrases and the proximity boost do not run across fields.
+const fieldPositionGap = 100
+
+func (bmx *BMX) Positions_fill() {
+	for doc_key, doc := range bmx.Docs {
+		doc.Positions = nil
+		if bmx.StorePositions {
+			doc.Positions = make(map[string][]int, len(doc.F_table))
+			if len(doc.Fields) == 0 {
+				addPositions(doc.Positions, doc.Tokens, 0)
+			} else {
+				// Fields are concatenated in name order in doc.Tokens
+				names := make([]string, 0, len(doc.Fields))
+				for name := range doc.Fields {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				start := 0
+				for _, name := range names {
+					tokens := doc.Fields[name].Tokens
+					addPositions(doc.Positions, tokens, start)
+					start += len(tokens) + fieldPositionGap
+				}
+			}
+		}
+		bmx.Docs[doc_key] = doc
+	}
+}
+
+func addPositions(positions map[string][]int, tokens []string, start int) {
+	for i, token := range tokens {
+		positions[token] = append(positions[token], start+i)
+	}
+}
+
+// containsPhrase reports whether the tree has a phrase node.
+func containsPhrase(node QueryNode) bool {
+	switch n := node.(type) {
+	case PhraseNode:
+		return true
+	case BooleanNode:
+		for _, clause := range n.Clauses {
+			if containsPhrase(clause.Node) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// phraseBitmap returns the documents matching the phrase tokens.
+func (bmx *BMX) phraseBitmap(tokens []string, slop int) *Bitmap {
+	matching := NewBitmap(len(bmx.DocIDs))
+	if len(tokens) == 0 {
+		return matching
+	}
+	// Candidates contain every token: start from the rarest posting list.
+	rarest := tokens[0]
+	for _, token := range tokens[1:] {
+		if len(bmx.NumAppearances[token]) < len(bmx.NumAppearances[rarest]) {
+			rarest = token
+		}
+	}
+	for _, doc_key := range bmx.NumAppearances[rarest] {
+		positions := bmx.Docs[doc_key].Positions
+		lists := make([][]int, len(tokens))
+		found := true
+		for i, token := range tokens {
+			if lists[i] = positions[token]; len(lists[i]) == 0 {
+				found = false
+				break
+			}
+		}
+		if !found {
+			continue
+		}
+		if (slop == 0 && exactPhrase(lists)) || (slop > 0 && minWindow(tokens, positions)-(len(tokens)-1) <= slop) {
+			matching.Add(bmx.Ordinals[doc_key])
+		}
+	}
+	return matching
+}
+
+// exactPhrase reports whether some p has lists[i] containing p+i for every i.
+func exactPhrase(lists [][]int) bool {
+	for _, start := range lists[0] {
+		match := true
+		for i := 1; i < len(lists); i++ {
+			j := sort.SearchInts(lists[i], start+i)
+			if j == len(lists[i]) || lists[i][j] != start+i {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// minWindow returns the smallest max-min distance of a set of distinct
+// positions holding one position per token: a token repeated in the phrase
+// needs as many positions as occurrences.
+func minWindow(tokens []string, positions map[string][]int) int {
+	type occurrence struct {
+		position int
+		token    string
+	}
+	need := map[string]int{}
+	for _, token := range tokens {
+		need[token]++
+	}
+	occurrences := []occurrence{}
+	for token := range need {
+		for _, position := range positions[token] {
+			occurrences = append(occurrences, occurrence{position, token})
+		}
+	}
+	sort.Slice(occurrences, func(i, j int) bool { return occurrences[i].position < occurrences[j].position })
+
+	best := math.MaxInt
+	missing := len(tokens)
+	left := 0
+	for _, right := range occurrences {
+		if need[right.token] > 0 {
+			missing--
+		}
+		need[right.token]--
+		// Shrink the window from the left while it holds every token.
+		for missing == 0 {
+			first := occurrences[left]
+			best = min(best, right.position-first.position)
+			need[first.token]++
+			if need[first.token] > 0 {
+				missing++
+			}
+			left++
+		}
+	}
+	return best
+}
+
+// minDistance returns the smallest distance between positions of two
+// sorted lists.
+func minDistance(a, b []int) int {
+	best := math.MaxInt
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		if a[i] < b[j] {
+			best = min(best, b[j]-a[i])
+			i++
+		} else {
+			best = min(best, a[i]-b[j])
+			j++
+		}
+	}
+	return best
+}
+
+// Proximity_boost_fill adds the proximity boost of every matching document
+// to the scores.
+func (query *Query) Proximity_boost_fill(bmx *BMX) {
+	if !query.hasProximityBoost(bmx) {
+		return
+	}
+	for doc_key, score := range query.ScoreTable {
+		if score == 0 {
+			continue
+		}
+		query.ScoreTable[doc_key] += query.proximityBoost(bmx, doc_key)
+	}
+}
+
+func (query *Query) hasProximityBoost(bmx *BMX) bool {
+	return query.ProximityBoost != 0 && bmx.StorePositions && len(query.orderedTokens) >= 2
+}
+
+// proximityBoost returns ProximityBoost * sum(1 / d), where d is the
+// smallest distance in the document between two tokens following each other
+// in the query.
+func (query *Query) proximityBoost(bmx *BMX, doc_key string) float64 {
+	positions := bmx.Docs[doc_key].Positions
+	proximity := 0.0
+	for i := 1; i < len(query.orderedTokens); i++ {
+		a, b := positions[query.orderedTokens[i-1]], positions[query.orderedTokens[i]]
+		if len(a) > 0 && len(b) > 0 {
+			if d := minDistance(a, b); d > 0 {
+				proximity += 1 / float64(d)
+			}
+		}
+	}
+	return query.ProximityBoost * proximity
+}
@@ -0,0 +1,83 @@
+package model
+
+import (
+	"math"
+	"slices"
+	"sort"
+	"testing"
+)
+
+func TestMinWindow(t *testing.T) {
+	tests := []struct {
+		name      string
+		tokens    []string
+		positions map[string][]int
+		want      int
+	}{
+		{"adjacent", []string{"a", "b"}, map[string][]int{"a": {0}, "b": {1}}, 1},
+		{"any order", []string{"a", "b"}, map[string][]int{"a": {5}, "b": {2}}, 3},
+		{"closest pair", []string{"a", "b", "c"}, map[string][]int{"a": {0, 10}, "b": {4, 11}, "c": {12}}, 2},
+		{"repeated token", []string{"a", "a"}, map[string][]int{"a": {2, 6, 7}}, 1},
+		{"repeated token once", []string{"a", "a"}, map[string][]int{"a": {2}}, math.MaxInt},
+		{"missing token", []string{"a", "b"}, map[string][]int{"a": {2}}, math.MaxInt},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := minWindow(tt.tokens, tt.positions)
+			if got != tt.want {
+				t.Errorf("minWindow = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPhraseRepeatedToken(t *testing.T) {
+	adapter := newTestAdapter(t, testDocs...)
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{`"the the"~1`, nil},
+		{`"the the"~2`, []string{"f"}},
+		{`"the the"~5`, []string{"a", "f"}},
+	}
+	for _, tt := range tests {
+		results, err := adapter.SearchQuery(tt.query, SearchOptions{TopK: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := slices.Clone(results.Keys)
+		sort.Strings(got)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("SearchQuery(%q) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+// TestPhraseAcrossFields checks that phrases match within a field but not
+// across the end of a field and the start of the next one.
+func TestPhraseAcrossFields(t *testing.T) {
+	adapter := newTestAdapter(t)
+	docs := []FieldedDocument{{ID: "a", Fields: map[string]string{"body": "the fox jumps", "title": "quick brown"}}}
+	if err := adapter.AddDocuments(docs); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{`"quick brown"`, 1},
+		{`"fox jumps"`, 1},
+		{`"jumps quick"`, 0},
+		{`"jumps quick"~5`, 0},
+	}
+	for _, tt := range tests {
+		results, err := adapter.SearchQuery(tt.query, SearchOptions{TopK: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results.Keys) != tt.want {
+			t.Errorf("SearchQuery(%q) = %v, want %d hits", tt.query, results.Keys, tt.want)
+		}
+	}
+}
@@ -0,0 +1,385 @@
+package model
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+	"sync"
+	"text/template"
+)
+
+// PromptExample is a few-shot example of query augmentation.
+type PromptExample struct {
+	Query            string
+	AugmentedQueries []string
+	Confidences      []float64 // Confidence of each augmented query, 1 when missing
+}
+
+// PromptTemplate is a versioned prompt for query augmentation. System and
+// User are text/template strings receiving .Query, .NumQueries, .Language
+// and .Confidence, set when the LLM must give a confidence per query.
+// Examples are sent as previous turns, answered with the JSON output
+// expected from the LLM. HyDE is the text/template asking for a
+// hypothetical passage, see HyDEOptions.
+type PromptTemplate struct {
+	Name     string
+	Version  string
+	Language string
+	System   string // Optional system message
+	User     string
+	Examples []PromptExample
+	HyDE     string // Defaults to DefaultHyDEPrompt
+}
+
+type promptData struct {
+	Query      string
+	NumQueries int
+	Language   string
+	Confidence bool
+}
+
+// Messages renders the template for query.
+func (t PromptTemplate) Messages(query string, numQueries int) ([]ConvMessage, error) {
+	return t.messages(query, numQueries, false)
+}
+
+// WeightedMessages renders the template for query, asking for the
+// confidence of each augmented query.
+func (t PromptTemplate) WeightedMessages(query string, numQueries int) ([]ConvMessage, error) {
+	return t.messages(query, numQueries, true)
+}
+
+func (t PromptTemplate) messages(query string, numQueries int, confidence bool) ([]ConvMessage, error) {
+	user, err := template.New(t.Name + "/user").Parse(t.User)
+	if err != nil {
+		return nil, err
+	}
+	render := func(tmpl *template.Template, data promptData) (string, error) {
+		var sb bytes.Buffer
+		err := tmpl.Execute(&sb, data)
+		return sb.String(), err
+	}
+
+	messages := []ConvMessage{}
+	if t.System != "" {
+		system, err := template.New(t.Name + "/system").Parse(t.System)
+		if err != nil {
+			return nil, err
+		}
+		content, err := render(system, promptData{query, numQueries, t.Language, confidence})
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, ConvMessage{Role: "system", Content: content})
+	}
+	for _, example := range t.Examples {
+		content, err := render(user, promptData{example.Query, len(example.AugmentedQueries), t.Language, confidence})
+		if err != nil {
+			return nil, err
+		}
+		answer, err := example.answer(confidence)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages,
+			ConvMessage{Role: "user", Content: content},
+			ConvMessage{Role: "assistant", Content: string(answer)},
+		)
+	}
+	content, err := render(user, promptData{query, numQueries, t.Language, confidence})
+	if err != nil {
+		return nil, err
+	}
+	return append(messages, ConvMessage{Role: "user", Content: content}), nil
+}
+
+// answer returns the JSON output expected for the example.
+func (example PromptExample) answer(confidence bool) (string, error) {
+	if !confidence {
+		answer, err := json.Marshal(struct {
+			Query            string   `json:"query"`
+			AugmentedQueries []string `json:"augmented queries"`
+		}{example.Query, example.AugmentedQueries})
+		return string(answer), err
+	}
+	type weighted struct {
+		Query      string  `json:"query"`
+		Confidence float64 `json:"confidence"`
+	}
+	queries := make([]weighted, len(example.AugmentedQueries))
+	for i, query := range example.AugmentedQueries {
+		queries[i] = weighted{query, 1}
+		if i < len(example.Confidences) {
+			queries[i].Confidence = example.Confidences[i]
+		}
+	}
+	answer, err := json.Marshal(struct {
+		Query            string     `json:"query"`
+		AugmentedQueries []weighted `json:"augmented queries"`
+	}{example.Query, queries})
+	return string(answer), err
+}
+
+// Fingerprint identifies the version and content of the template, so that
+// augmentations generated with another prompt are not reused.
+func (t PromptTemplate) Fingerprint() string {
+	content, _ := json.Marshal(t)
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:8])
+}
+
+const promptOutputFormat = `Output JSON only, like {"query": "original query", "augmented queries": ["augmented query 1", "augmented query 2", ...]}`
+
+const promptConfidenceOutputFormat = `Output JSON only, like {"query": "original query", "augmented queries": [{"query": "augmented query 1", "confidence": 0.9}, ...]}`
+
+// promptTemplatesMu guards promptTemplatesDict, which is read by concurrent
+// searches.
+var promptTemplatesMu sync.RWMutex
+
+var promptTemplatesDict = map[string]PromptTemplate{
+	"english": {
+		Name:     "augment-english",
+		Version:  "1",
+		Language: "english",
+		System:   "You are an intelligent query augmentation tool for a search engine.",
+		User: `Augment the query with {{.NumQueries}} similar queries, in English.
+{{if .Confidence}}For each one, give your confidence between 0 and 1 that it has the same intent as the original query.
+` + promptConfidenceOutputFormat + `{{else}}` + promptOutputFormat + `{{end}}
+Input query: {{.Query}}
+Output:`,
+		Examples: []PromptExample{{
+			Query:            "how to lower blood pressure",
+			AugmentedQueries: []string{"ways to reduce hypertension", "natural remedies for high blood pressure"},
+			Confidences:      []float64{0.9, 0.7},
+		}},
+
+		HyDE: DefaultHyDEPrompt,
+	},
+	"french": {
+		Name:     "augment-french",
+		Version:  "1",
+		Language: "french",
+		System:   "Tu es un outil d'augmentation de requêtes pour un moteur de recherche.",
+		User: `Propose {{.NumQueries}} requêtes similaires à la requête, en français.
+{{if .Confidence}}Pour chacune, donne ta confiance entre 0 et 1 qu'elle a la même intention que la requête originale.
+Réponds uniquement en JSON, comme {"query": "requête originale", "augmented queries": [{"query": "requête augmentée 1", "confidence": 0.9}, ...]}{{else}}Réponds uniquement en JSON, comme {"query": "requête originale", "augmented queries": ["requête augmentée 1", "requête augmentée 2", ...]}{{end}}
+Requête : {{.Query}}
+Réponse :`,
+		Examples: []PromptExample{{
+			Query:            "comment faire baisser la tension artérielle",
+			AugmentedQueries: []string{"réduire l'hypertension", "remèdes naturels contre la tension élevée"},
+			Confidences:      []float64{0.9, 0.7},
+		}},
+		HyDE: `Écris un court passage qui répond à la question ci-dessous, tel qu'il pourrait apparaître dans un document.
+Utilise au plus {{.MaxWords}} mots et écris uniquement le passage.
+Question : {{.Query}}
+Passage :`,
+	},
+	"german": {
+		Name:     "augment-german",
+		Version:  "1",
+		Language: "german",
+		System:   "Du bist ein Werkzeug zur Erweiterung von Suchanfragen für eine Suchmaschine.",
+		User: `Erzeuge {{.NumQueries}} ähnliche Suchanfragen zur Anfrage, auf Deutsch.
+{{if .Confidence}}Gib für jede deine Zuversicht zwischen 0 und 1 an, dass sie dieselbe Absicht wie die ursprüngliche Anfrage hat.
+Antworte nur mit JSON, etwa {"query": "ursprüngliche Anfrage", "augmented queries": [{"query": "erweiterte Anfrage 1", "confidence": 0.9}, ...]}{{else}}Antworte nur mit JSON, etwa {"query": "ursprüngliche Anfrage", "augmented queries": ["erweiterte Anfrage 1", "erweiterte Anfrage 2", ...]}{{end}}
+Anfrage: {{.Query}}
+Antwort:`,
+		Examples: []PromptExample{{
+			Query:            "wie senkt man den Blutdruck",
+			AugmentedQueries: []string{"Bluthochdruck reduzieren", "natürliche Mittel gegen hohen Blutdruck"},
+			Confidences:      []float64{0.9, 0.7},
+		}},
+
+		HyDE: `Schreibe einen kurzen Abschnitt, der die folgende Frage beantwortet, wie er in einem Dokument stehen könnte.
+Verwende höchstens {{.MaxWords}} Wörter und gib nur den Abschnitt aus.
+Frage: {{.Query}}
+Abschnitt:`,
+	},
+}
+
+// GetPromptTemplate returns the augmentation template of a language, the
+// English one when there is none.
+func GetPromptTemplate(language string) PromptTemplate {
+	promptTemplatesMu.RLock()
+	defer promptTemplatesMu.RUnlock()
+	if t, ok := promptTemplatesDict[language]; ok {
+		return t
+	}
+	return promptTemplatesDict["english"]
+}
+
+// RegisterPromptTemplate sets the default augmentation template of a language.
+func RegisterPromptTemplate(language string, t PromptTemplate) {
+	promptTemplatesMu.Lock()
+	defer promptTemplatesMu.Unlock()
+	promptTemplatesDict[language] = t
+}
+
+// AugmentationCache stores generated augmented queries and hypothetical
+// passages, keyed by template fingerprint, query and number of queries (max
+// words of a passage), so that it can be shared between indexes and
+// languages. It returns and stores copies of the queries.
+type AugmentationCache struct {
+	mu       sync.Mutex
+	entries  map[augmentationKey][]string
+	weighted map[augmentationKey][]WeightedQuery
+	passages map[augmentationKey]string
+}
+
+type augmentationKey struct {
+	fingerprint string
+	query       string
+	numQueries  int
+}
+
+func NewAugmentationCache() *AugmentationCache {
+	return &AugmentationCache{
+		entries:  map[augmentationKey][]string{},
+		weighted: map[augmentationKey][]WeightedQuery{},
+		passages: map[augmentationKey]string{},
+	}
+}
+
+func (c *AugmentationCache) Get(fingerprint string, query string, numQueries int) ([]string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	queries, ok := c.entries[augmentationKey{fingerprint, query, numQueries}]
+	return slices.Clone(queries), ok
+}
+
+func (c *AugmentationCache) Put(fingerprint string, query string, numQueries int, queries []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[augmentationKey{fingerprint, query, numQueries}] = slices.Clone(queries)
+}
+
+// GetWeighted and PutWeighted store augmented queries generated with their
+// confidence, apart from the plain ones.
+func (c *AugmentationCache) GetWeighted(fingerprint string, query string, numQueries int) ([]WeightedQuery, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	queries, ok := c.weighted[augmentationKey{fingerprint, query, numQueries}]
+	return cloneWeightedQueries(queries), ok
+}
+
+func (c *AugmentationCache) PutWeighted(fingerprint string, query string, numQueries int, queries []WeightedQuery) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.weighted[augmentationKey{fingerprint, query, numQueries}] = cloneWeightedQueries(queries)
+}
+
+// GetPassage and PutPassage store hypothetical passages of at most maxWords
+// words.
+func (c *AugmentationCache) GetPassage(fingerprint string, query string, maxWords int) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	passage, ok := c.passages[augmentationKey{fingerprint, query, maxWords}]
+	return passage, ok
+}
+
+func (c *AugmentationCache) PutPassage(fingerprint string, query string, maxWords int, passage string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.passages[augmentationKey{fingerprint, query, maxWords}] = passage
+}
+
+func cloneWeightedQueries(queries []WeightedQuery) []WeightedQuery {
+	if queries == nil {
+		return nil
+	}
+	clones := make([]WeightedQuery, len(queries))
+	for i, query := range queries {
+		clones[i] = query
+		clones[i].Tokens = slices.Clone(query.Tokens)
+	}
+	return clones
+}
+
+// SetPromptTemplate sets the augmentation template of the index, replacing
+// the default template of its language.
+func (adapter *BMXAdapter) SetPromptTemplate(t PromptTemplate) {
+	adapter.promptTemplate = &t
+}
+
+// PromptTemplate returns the augmentation template of the index.
+func (adapter *BMXAdapter) PromptTemplate() PromptTemplate {
+	if adapter.promptTemplate != nil {
+		return *adapter.promptTemplate
+	}
+	return GetPromptTemplate(adapter.bmx.TextPreprocessor.Language())
+}
+
+// SetAugmentationCache replaces the augmentation cache of the index, for
+// instance to share one between indexes; nil disables caching.
+func (adapter *BMXAdapter) SetAugmentationCache(cache *AugmentationCache) {
+	adapter.augmentations = cache
+}
+
+// AugmentQuery generates augmented queries with the template of the index,
+// reusing cached ones generated with the same template.
+func (adapter *BMXAdapter) AugmentQuery(query string, num_augmented_queries int) ([]string, error) {
+	tmpl := adapter.PromptTemplate()
+	fingerprint := tmpl.Fingerprint()
+	if adapter.augmentations != nil {
+		if queries, ok := adapter.augmentations.Get(fingerprint, query, num_augmented_queries); ok {
+			return queries, nil
+		}
+	}
+	queries, err := GenerateAugmentedQueriesWithTemplate(query, num_augmented_queries, tmpl)
+	if err != nil {
+		return nil, err
+	}
+	if adapter.augmentations != nil {
+		adapter.augmentations.Put(fingerprint, query, num_augmented_queries, queries)
+	}
+	return queries, nil
+}
+
+// AugmentQueryWeighted is AugmentQuery with the confidence the LLM gives each
+// augmented query as its weight.
+func (adapter *BMXAdapter) AugmentQueryWeighted(query string, num_augmented_queries int) ([]WeightedQuery, error) {
+	tmpl := adapter.PromptTemplate()
+	fingerprint := tmpl.Fingerprint()
+	if adapter.augmentations != nil {
+		if queries, ok := adapter.augmentations.GetWeighted(fingerprint, query, num_augmented_queries); ok {
+			return queries, nil
+		}
+	}
+	queries, err := GenerateWeightedAugmentedQueriesWithTemplate(query, num_augmented_queries, tmpl)
+	if err != nil {
+		return nil, err
+	}
+	if adapter.augmentations != nil {
+		adapter.augmentations.PutWeighted(fingerprint, query, num_augmented_queries, queries)
+	}
+	return queries, nil
+}
+
+// HypotheticalDocument generates a hypothetical passage answering query with
+// the HyDE prompt of opts, else of the index template, reusing a cached
+// passage generated with the same prompt.
+func (adapter *BMXAdapter) HypotheticalDocument(query string, opts HyDEOptions) (string, error) {
+	tmpl := adapter.PromptTemplate()
+	if opts.Prompt != "" {
+		tmpl.HyDE = opts.Prompt
+	}
+	opts.Prompt = tmpl.HyDE
+	opts = opts.withDefaults()
+	fingerprint := tmpl.Fingerprint()
+	if adapter.augmentations != nil {
+		if passage, ok := adapter.augmentations.GetPassage(fingerprint, query, opts.MaxWords); ok {
+			return passage, nil
+		}
+	}
+	passage, err := GenerateHypotheticalDocument(query, opts)
+	if err != nil {
+		return "", err
+	}
+	if adapter.augmentations != nil {
+		adapter.augmentations.PutPassage(fingerprint, query, opts.MaxWords, passage)
+	}
+	return passage, nil
+}
@@ -0,0 +1,74 @@
+package model
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuiltinPromptTemplates(t *testing.T) {
+	for _, language := range []string{"english", "french", "german"} {
+		tmpl := GetPromptTemplate(language)
+		if tmpl.Version != "1" {
+			t.Errorf("%s template version = %q, want 1", language, tmpl.Version)
+		}
+		if tmpl.HyDE == "" {
+			t.Errorf("%s template has no HyDE prompt", language)
+			continue
+		}
+		prompt, err := (HyDEOptions{Prompt: tmpl.HyDE}).withDefaults().prompt("q")
+		if err != nil {
+			t.Errorf("%s HyDE prompt: %v", language, err)
+		} else if !strings.Contains(prompt, "100") {
+			t.Errorf("%s HyDE prompt %q does not give the word limit", language, prompt)
+		}
+	}
+	if GetPromptTemplate("english").HyDE != DefaultHyDEPrompt {
+		t.Error("english HyDE prompt is not DefaultHyDEPrompt")
+	}
+}
+
+// TestAugmentationCacheCopies checks that callers cannot change the cached
+// augmentations.
+func TestAugmentationCacheCopies(t *testing.T) {
+	cache := NewAugmentationCache()
+	queries := []string{"a", "b"}
+	cache.Put("f", "q", 2, queries)
+	queries[0] = "changed"
+	got, _ := cache.Get("f", "q", 2)
+	got[1] = "changed"
+	if got, _ := cache.Get("f", "q", 2); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("cached queries = %q, want [a b]", got)
+	}
+
+	weighted := []WeightedQuery{{Text: "a", Weight: 0.5, Tokens: []string{"a"}}}
+	cache.PutWeighted("f", "q", 1, weighted)
+	weighted[0].Tokens[0] = "changed"
+	gotWeighted, _ := cache.GetWeighted("f", "q", 1)
+	gotWeighted[0].Weight = 1
+	want := []WeightedQuery{{Text: "a", Weight: 0.5, Tokens: []string{"a"}}}
+	if got, _ := cache.GetWeighted("f", "q", 1); !reflect.DeepEqual(got, want) {
+		t.Errorf("cached weighted queries = %+v, want %+v", got, want)
+	}
+}
+
+// TestCachedHypotheticalDocument checks that a cached passage is searched
+// without calling the LLM, and that it is keyed by the HyDE prompt.
+func TestCachedHypotheticalDocument(t *testing.T) {
+	adapter := newTestAdapter(t, testDocs...)
+	adapter.augmentations.PutPassage(adapter.PromptTemplate().Fingerprint(), "brown", 100, "Bears eat fish.")
+	results, err := adapter.SearchAugmentedWithOptions("brown", AugmentOptions{Strategy: AugmentHyDE, Weight: 0.3}, SearchOptions{TopK: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results.TotalHits != 2 || results.Keys[0] != "d" {
+		t.Errorf("results = %q with %d hits, want d, matching the query and the passage, first of 2", results.Keys, results.TotalHits)
+	}
+
+	tmpl := adapter.PromptTemplate()
+	tmpl.HyDE = "Answer {{.Query}}"
+	adapter.SetPromptTemplate(tmpl)
+	if _, ok := adapter.augmentations.GetPassage(adapter.PromptTemplate().Fingerprint(), "brown", 100); ok {
+		t.Error("passage cached for another HyDE prompt")
+	}
+}
@@ -0,0 +1,544 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Occur says how a clause constrains the documents of a boolean query.
+type Occur int
+
+const (
+	Should Occur = iota
+	Must
+	MustNot
+)
+
+// QueryNode is a node of a parsed query tree.
+type QueryNode interface {
+	String() string
+}
+
+// TermNode is a query term. Its text goes through the index's text
+// preprocessor, so it may yield zero, one or several tokens, each expanded
+// to the vocabulary terms within Fuzziness edits. A text containing * or ?
+// is instead a pattern matched against the lowercased vocabulary.
+type TermNode struct {
+	Text      string
+	Boost     float64
+	Fuzziness int
+}
+
+// IsPattern reports whether the term is a prefix or wildcard pattern.
+func (n TermNode) IsPattern() bool {
+	return strings.ContainsAny(n.Text, "*?")
+}
+
+// newTermNode parses the fuzzy suffix of a word: term~N, or term~ for 2 edits.
+func newTermNode(word string) (TermNode, error) {
+	node := TermNode{Text: word, Boost: 1}
+	if i := strings.LastIndex(word, "~"); i > 0 {
+		node.Text = word[:i]
+		node.Fuzziness = 2
+		if i+1 < len(word) {
+			fuzziness, err := strconv.Atoi(word[i+1:])
+			if err != nil || fuzziness < 0 || fuzziness > 2 {
+				return node, fmt.Errorf("invalid fuzziness %q, expected 0, 1 or 2", word[i:])
+			}
+			node.Fuzziness = fuzziness
+		}
+	}
+	return node, nil
+}
+
+type Clause struct {
+	Occur Occur
+	Node  QueryNode
+}
+
+// BooleanNode combines clauses: documents must match every Must clause, no
+// MustNot clause and, when there is no Must clause, at least one Should clause.
+type BooleanNode struct {
+	Clauses []Clause
+	Boost   float64
+}
+
+func (n TermNode) String() string {
+	s := n.Text
+	if n.Fuzziness > 0 {
+		s += fmt.Sprintf("~%d", n.Fuzziness)
+	}
+	if n.Boost != 1 {
+		s += fmt.Sprintf("^%g", n.Boost)
+	}
+	return s
+}
+
+func (n BooleanNode) String() string {
+	parts := make([]string, 0, len(n.Clauses))
+	for _, clause := range n.Clauses {
+		prefix := map[Occur]string{Should: "", Must: "+", MustNot: "-"}[clause.Occur]
+		parts = append(parts, prefix+clause.Node.String())
+	}
+	s := "(" + strings.Join(parts, " ") + ")"
+	if n.Boost != 1 {
+		s += fmt.Sprintf("^%g", n.Boost)
+	}
+	return s
+}
+
+type lexemeKind int
+
+const (
+	lexWord lexemeKind = iota
+	lexAnd
+	lexOr
+	lexNot
+	lexPlus
+	lexMinus
+	lexLParen
+	lexRParen
+	lexBoost
+	lexPhrase
+	lexEOF
+)
+
+type lexeme struct {
+	kind  lexemeKind
+	text  string
+	boost float64
+	slop  int
+	pos   int
+}
+
+var queryOperators = map[string]lexemeKind{"AND": lexAnd, "OR": lexOr, "NOT": lexNot, "&&": lexAnd, "||": lexOr}
+
+func isQuerySyntax(r rune) bool {
+	return unicode.IsSpace(r) || r == '(' || r == ')' || r == '^' || r == '"'
+}
+
+func lexQuery(text string) ([]lexeme, error) {
+	lexemes := []lexeme{}
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			lexemes = append(lexemes, lexeme{kind: lexLParen, pos: i})
+			i++
+		case r == ')':
+			lexemes = append(lexemes, lexeme{kind: lexRParen, pos: i})
+			i++
+		case r == '"':
+			j := i + 1
+			for j < len(runes) && runes[j] != '"' {
+				j++
+			}
+			if j == len(runes) {
+				return nil, fmt.Errorf("unterminated phrase at position %d", i)
+			}
+			phrase := lexeme{kind: lexPhrase, text: string(runes[i+1 : j]), pos: i}
+			j++
+			if j < len(runes) && runes[j] == '~' {
+				k := j + 1
+				for k < len(runes) && unicode.IsDigit(runes[k]) {
+					k++
+				}
+				slop, err := strconv.Atoi(string(runes[j+1 : k]))
+				if err != nil {
+					return nil, fmt.Errorf("invalid slop %q at position %d", string(runes[j:k]), j)
+				}
+				phrase.slop = slop
+				j = k
+			}
+			lexemes = append(lexemes, phrase)
+			i = j
+		case r == '^':
+			j := i + 1
+			for j < len(runes) && !isQuerySyntax(runes[j]) {
+				j++
+			}
+			boost, err := strconv.ParseFloat(string(runes[i+1:j]), 64)
+			if err != nil || boost < 0 {
+				return nil, fmt.Errorf("invalid boost %q at position %d", string(runes[i:j]), i)
+			}
+			lexemes = append(lexemes, lexeme{kind: lexBoost, boost: boost, pos: i})
+			i = j
+		case (r == '+' || r == '-') && i+1 < len(runes) && !unicode.IsSpace(runes[i+1]):
+			kind := lexPlus
+			if r == '-' {
+				kind = lexMinus
+			}
+			lexemes = append(lexemes, lexeme{kind: kind, pos: i})
+			i++
+		default:
+			j := i
+			for j < len(runes) && !isQuerySyntax(runes[j]) {
+				j++
+			}
+			word := string(runes[i:j])
+			kind, ok := queryOperators[word]
+			if !ok {
+				kind = lexWord
+			}
+			lexemes = append(lexemes, lexeme{kind: kind, text: word, pos: i})
+			i = j
+		}
+	}
+	return append(lexemes, lexeme{kind: lexEOF, pos: len(runes)}), nil
+}
+
+type queryParser struct {
+	lexemes []lexeme
+	pos     int
+}
+
+func (p *queryParser) peek() lexeme {
+	return p.lexemes[p.pos]
+}
+
+func (p *queryParser) next() lexeme {
+	l := p.lexemes[p.pos]
+	if l.kind != lexEOF {
+		p.pos++
+	}
+	return l
+}
+
+// ParseQuery parses a boolean query. The syntax is:
+//
+//	+term      the term is required
+//	-term      the term is excluded (same as NOT term)
+//	a AND b    both are required
+//	a OR b     at least one is required
+//	(a b)      grouping
+//	term^2     boosts the weight of the term (or group)
+//	"a b"      phrase, needs the positional index
+//	"a b"~3    the phrase tokens within a window of 3 extra positions
+//	term~1     fuzzy term, up to 1 edit (term~ allows 2)
+//	te*        prefix, te?m* wildcard patterns
+//
+// Precedence from lowest to highest is OR, juxtaposition, AND, then the
+// prefix operators. Juxtaposed clauses without prefix are optional.
+func ParseQuery(text string) (QueryNode, error) {
+	lexemes, err := lexQuery(text)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{lexemes: lexemes}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if l := p.peek(); l.kind != lexEOF {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.describe(l), l.pos)
+	}
+	return node, nil
+}
+
+func (p *queryParser) describe(l lexeme) string {
+	switch l.kind {
+	case lexRParen:
+		return ")"
+	case lexLParen:
+		return "("
+	case lexEOF:
+		return "end of query"
+	case lexBoost:
+		return "^"
+	case lexPhrase:
+		return `"` + l.text + `"`
+	default:
+		return l.text
+	}
+}
+
+func (p *queryParser) parseOr() (QueryNode, error) {
+	nodes := []QueryNode{}
+	for {
+		node, err := p.parseSequence()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+		if p.peek().kind != lexOr {
+			break
+		}
+		p.next()
+	}
+	if len(nodes) == 1 {
+		return nodes[0], nil
+	}
+	or := BooleanNode{Boost: 1}
+	for _, node := range nodes {
+		or.Clauses = append(or.Clauses, Clause{Occur: Should, Node: node})
+	}
+	return or, nil
+}
+
+func (p *queryParser) parseSequence() (QueryNode, error) {
+	clauses := []Clause{}
+	for {
+		switch p.peek().kind {
+		case lexOr, lexRParen, lexEOF:
+			if len(clauses) == 0 {
+				l := p.peek()
+				return nil, fmt.Errorf("expected a term before %q at position %d", p.describe(l), l.pos)
+			}
+			if len(clauses) == 1 && clauses[0].Occur == Should {
+				return clauses[0].Node, nil
+			}
+			return BooleanNode{Clauses: clauses, Boost: 1}, nil
+		}
+		clause, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		clauses = append(clauses, clause)
+	}
+}
+
+func (p *queryParser) parseAnd() (Clause, error) {
+	clauses := []Clause{}
+	for {
+		clause, err := p.parseUnary()
+		if err != nil {
+			return Clause{}, err
+		}
+		clauses = append(clauses, clause)
+		if p.peek().kind != lexAnd {
+			break
+		}
+		p.next()
+	}
+	if len(clauses) == 1 {
+		return clauses[0], nil
+	}
+	and := BooleanNode{Boost: 1}
+	for _, clause := range clauses {
+		if clause.Occur != MustNot {
+			clause.Occur = Must
+		}
+		and.Clauses = append(and.Clauses, clause)
+	}
+	return Clause{Occur: Should, Node: and}, nil
+}
+
+func (p *queryParser) parseUnary() (Clause, error) {
+	switch p.peek().kind {
+	case lexPlus:
+		p.next()
+		clause, err := p.parseUnary()
+		if clause.Occur == Should {
+			clause.Occur = Must
+		}
+		return clause, err
+	case lexMinus, lexNot:
+		p.next()
+		clause, err := p.parseUnary()
+		clause.Occur = MustNot
+		return clause, err
+	}
+	node, err := p.parsePrimary()
+	if err != nil {
+		return Clause{}, err
+	}
+	if p.peek().kind == lexBoost {
+		boost := p.next().boost
+		switch n := node.(type) {
+		case TermNode:
+			n.Boost *= boost
+			node = n
+		case PhraseNode:
+			n.Boost *= boost
+			node = n
+		case BooleanNode:
+			n.Boost *= boost
+			node = n
+		}
+	}
+	return Clause{Occur: Should, Node: node}, nil
+}
+
+func (p *queryParser) parsePrimary() (QueryNode, error) {
+	l := p.next()
+	switch l.kind {
+	case lexWord:
+		node, err := newTermNode(l.text)
+		if err != nil {
+			return nil, fmt.Errorf("%v at position %d", err, l.pos)
+		}
+		return node, nil
+	case lexPhrase:
+		return PhraseNode{Text: l.text, Slop: l.slop, Boost: 1}, nil
+	case lexLParen:
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != lexRParen {
+			return nil, fmt.Errorf("expected ) at position %d", closing.pos)
+		}
+		if b, ok := node.(BooleanNode); ok {
+			return b, nil
+		}
+		// Keep the group so a boost applies to all of it.
+		return BooleanNode{Clauses: []Clause{{Occur: Should, Node: node}}, Boost: 1}, nil
+	default:
+		return nil, fmt.Errorf("unexpected %q at position %d", p.describe(l), l.pos)
+	}
+}
+
+// nodeText returns the words of the terms and phrases of a query tree,
+// without its operators, for language detection.
+func nodeText(node QueryNode) string {
+	switch n := node.(type) {
+	case TermNode:
+		return n.Text
+	case PhraseNode:
+		return n.Text
+	case BooleanNode:
+		texts := make([]string, 0, len(n.Clauses))
+		for _, clause := range n.Clauses {
+			texts = append(texts, nodeText(clause.Node))
+		}
+		return strings.Join(texts, " ")
+	}
+	return ""
+}
+
+// compile fills the query tokens from the positive terms of the tree,
+// weighted by their boosts, and returns the documents allowed by the tree
+// (nil when the tree does not constrain them).
+func (query *Query) compile(bmx *BMX, node QueryNode, boost float64, negated bool) *Bitmap {
+	switch n := node.(type) {
+	case TermNode:
+		expanded := map[string]float64{}
+		if n.IsPattern() {
+			expanded = bmx.expandPattern(bmx.normalizePattern(strings.ToLower(n.Text)), query.Expansion)
+		} else {
+			tokens := query.process(bmx, n.Text)
+			if len(tokens) == 0 {
+				return nil
+			}
+			for _, token := range tokens {
+				terms := query.expandToken(bmx, token)
+				if n.Fuzziness > 0 {
+					terms = bmx.expandFuzzy(token, n.Fuzziness, query.Expansion)
+				}
+				for term, factor := range terms {
+					expanded[term] += factor
+				}
+			}
+			if !negated {
+				query.orderedTokens = append(query.orderedTokens, tokens...)
+			}
+		}
+		matching := NewBitmap(len(bmx.DocIDs))
+		for term, factor := range expanded {
+			if !negated {
+				query.Tokens[term] += boost * n.Boost * factor
+				query.TotalWeight += boost * n.Boost * factor
+			}
+			for _, doc_key := range bmx.NumAppearances[term] {
+				matching.Add(bmx.Ordinals[doc_key])
+			}
+		}
+		return matching
+	case PhraseNode:
+		tokens := query.process(bmx, n.Text)
+		if len(tokens) == 0 {
+			return nil
+		}
+		query.addTokens(tokens, boost*n.Boost, negated)
+		return bmx.phraseBitmap(tokens, n.Slop)
+	case BooleanNode:
+		var musts, shoulds, mustNots []*Bitmap
+		unconstrainedShould := false
+		for _, clause := range n.Clauses {
+			matching := query.compile(bmx, clause.Node, boost*n.Boost, negated != (clause.Occur == MustNot))
+			switch clause.Occur {
+			case Must:
+				if matching != nil {
+					musts = append(musts, matching)
+				}
+			case Should:
+				if matching == nil {
+					unconstrainedShould = true
+				}
+				shoulds = append(shoulds, matching)
+			case MustNot:
+				if matching != nil {
+					mustNots = append(mustNots, matching)
+				}
+			}
+		}
+		var result *Bitmap
+		switch {
+		case len(musts) > 0:
+			result = musts[0]
+			for _, m := range musts[1:] {
+				result.And(m)
+			}
+		case len(shoulds) > 0 && !unconstrainedShould && !hasMustClause(n):
+			result = NewBitmap(len(bmx.DocIDs))
+			for _, s := range shoulds {
+				result.Or(s)
+			}
+		}
+		if len(mustNots) > 0 {
+			if result == nil {
+				result = FullBitmap(len(bmx.DocIDs))
+			}
+			for _, m := range mustNots {
+				result.AndNot(m)
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+// addTokens adds the tokens of a positive clause to the query.
+func (query *Query) addTokens(tokens []string, weight float64, negated bool) {
+	if negated {
+		return
+	}
+	for _, token := range tokens {
+		query.Tokens[token] += weight
+		query.TotalWeight += weight
+	}
+	query.orderedTokens = append(query.orderedTokens, tokens...)
+}
+
+func hasMustClause(n BooleanNode) bool {
+	for _, clause := range n.Clauses {
+		if clause.Occur == Must {
+			return true
+		}
+	}
+	return false
+}
+
+// SearchQuery parses a boolean query (see ParseQuery) and searches with it.
+// Required and excluded clauses restrict the candidates; BMX scores the
+// positive terms with their boosts as weights.
+func (adapter *BMXAdapter) SearchQuery(query string, opts SearchOptions) (SearchResults, error) {
+	node, err := ParseQuery(query)
+	if err != nil {
+		return SearchResults{}, err
+	}
+	if containsPhrase(node) && !adapter.bmx.StorePositions {
+		return SearchResults{}, fmt.Errorf("phrase queries need the positional index, see EnablePositionalIndex")
+	}
+	q := opts.query(query)
+	q.Node = node
+	q.Initialize(adapter.bmx)
+	results := topResults(&q, adapter.bmx, opts)
+	results.Facets = q.Facets(adapter.bmx, opts)
+	return results, nil
+}
@@ -0,0 +1,86 @@
+package model
+
+import (
+	"slices"
+	"sort"
+	"testing"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{`a b`, `(a b)`},
+		{`+a -b c`, `(+a -b c)`},
+		{`a AND b OR c`, `((+a +b) c)`},
+		{`a OR b c`, `(a (b c))`},
+		{`NOT a b`, `(-a b)`},
+		{`(a OR b)^2 c^3`, `((a b)^2 c^3)`},
+		{`"a b"~3`, `"a b"~3`},
+		{`te*m~1`, `te*m~1`},
+		{`fuzzy~`, `fuzzy~2`},
+		{`"x"^0.5 -(y z)`, `("x"^0.5 -(y z))`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			node, err := ParseQuery(tt.query)
+			if err != nil {
+				t.Fatalf("ParseQuery(%q): %v", tt.query, err)
+			}
+			if got := node.String(); got != tt.want {
+				t.Errorf("ParseQuery(%q) = %s, want %s", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	for _, query := range []string{`a~3`, `(a b`, `a)`, `"open`, `AND`, `a AND`} {
+		if node, err := ParseQuery(query); err == nil {
+			t.Errorf("ParseQuery(%q) = %s, want an error", query, node)
+		}
+	}
+}
+
+func TestSearchQuery(t *testing.T) {
+	adapter := newTestAdapter(t, testDocs...)
+	tests := []struct {
+		query string
+		want  []string // Matching keys, in any order
+	}{
+		{`+fox -lazy`, []string{"b", "f"}},
+		{`fox AND brown`, []string{"a"}},
+		{`cat OR bears`, []string{"c", "d"}},
+		{`"quick brown"`, []string{"a"}},
+		{`"quick fox"`, nil},
+		{`"quick fox"~1`, []string{"a", "b"}},
+		{`cat*`, []string{"c", "e"}},
+		{`do?`, []string{"a"}},
+		{`brwn~1`, []string{"a", "d"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			results, err := adapter.SearchQuery(tt.query, SearchOptions{TopK: 10})
+			if err != nil {
+				t.Fatalf("SearchQuery(%q): %v", tt.query, err)
+			}
+			got := append([]string(nil), results.Keys...)
+			sort.Strings(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("SearchQuery(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchQueryBoost(t *testing.T) {
+	adapter := newTestAdapter(t, testDocs...)
+	results, err := adapter.SearchQuery(`fox cat^3`, SearchOptions{TopK: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results.Keys) == 0 || results.Keys[0] != "c" {
+		t.Errorf("SearchQuery(fox cat^3) = %v, want c first", results.Keys)
+	}
+}
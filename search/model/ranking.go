@@ -0,0 +1,184 @@
+package model
+
+import (
+	"container/heap"
+	"math"
+)
+
+// ScoreNormalization selects the scores reported in SearchResults.Scores.
+// Ranking always uses the raw scores.
+type ScoreNormalization int
+
+const (
+	// NormalizeBMX divides the raw score by the BMX theoretical max score.
+	NormalizeBMX ScoreNormalization = iota
+	NormalizeRaw
+	// NormalizeMinMax maps the scores of the ranked documents to [0, 1].
+	NormalizeMinMax
+	// NormalizeSoftmax turns the scores of the ranked documents into a
+	// probability distribution.
+	NormalizeSoftmax
+)
+
+type TotalHitsRelation string
+
+const (
+	TotalHitsEqual TotalHitsRelation = "eq"
+	TotalHitsGTE   TotalHitsRelation = "gte"
+)
+
+// Cursor is the position of a hit in the ranking: its raw score and doc id.
+type Cursor struct {
+	Score float64
+	Key   string
+}
+
+// before reports whether a ranks before b: higher score first, then
+// lower doc id, so the order does not depend on map iteration.
+func (a Cursor) before(b Cursor) bool {
+	if a.Score != b.Score {
+		return a.Score > b.Score
+	}
+	return a.Key < b.Key
+}
+
+// hitHeap is a min-heap on the ranking order, keeping the best hits.
+type hitHeap []Cursor
+
+func (h hitHeap) Len() int           { return len(h) }
+func (h hitHeap) Less(i, j int) bool { return h[j].before(h[i]) }
+func (h hitHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *hitHeap) Push(x any)        { *h = append(*h, x.(Cursor)) }
+func (h *hitHeap) Pop() any {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// ranked returns the n best eligible hits ranked after the cursor, in order.
+// A nil eligible allows every document.
+func (query *Query) ranked(n int, after *Cursor, eligible func(key string) bool) []Cursor {
+	if n <= 0 {
+		return nil
+	}
+	h := make(hitHeap, 0, n)
+	for key, score := range query.ScoreTable {
+		if eligible != nil && !eligible(key) {
+			continue
+		}
+		hit := Cursor{Score: score, Key: key}
+		if after != nil && !after.before(hit) {
+			continue
+		}
+		if len(h) < n {
+			heap.Push(&h, hit)
+		} else if hit.before(h[0]) {
+			h[0] = hit
+			heap.Fix(&h, 0)
+		}
+	}
+	hits := make([]Cursor, len(h))
+	for i := len(h) - 1; i >= 0; i-- {
+		hits[i] = heap.Pop(&h).(Cursor)
+	}
+	return hits
+}
+
+// totalHits counts the eligible documents with a positive score, up to
+// limit when it is positive.
+func (query *Query) totalHits(limit int, eligible func(key string) bool) (int, TotalHitsRelation) {
+	total := 0
+	for key, score := range query.ScoreTable {
+		if score > 0 && eligible(key) {
+			total++
+			if limit > 0 && total > limit {
+				return limit, TotalHitsGTE
+			}
+		}
+	}
+	return total, TotalHitsEqual
+}
+
+// eligible returns whether a document may be returned, allowed by the
+// filters and with a reported score of at least opts.MinScore, and the
+// function computing the reported score.
+func (q *Query) eligible(bmx *BMX, opts SearchOptions) (func(key string) bool, func(key string) float64) {
+	allowed := func(key string) bool { return q.allows(bmx, key) }
+	normalize := q.normalizer(opts.Normalization, allowed)
+	if opts.MinScore <= 0 {
+		return allowed, normalize
+	}
+	return func(key string) bool {
+		return allowed(key) && normalize(key) >= opts.MinScore
+	}, normalize
+}
+
+// topResults returns the page of an initialized query described by opts.
+func topResults(q *Query, bmx *BMX, opts SearchOptions) SearchResults {
+	eligible, normalize := q.eligible(bmx, opts)
+	offset := max(opts.Offset, 0)
+	hits := q.ranked(offset+opts.TopK, opts.SearchAfter, eligible)
+	page := hits[min(offset, len(hits)):]
+
+	results := SearchResults{Keys: []string{}, Scores: []float64{}}
+	for _, hit := range page {
+		results.Keys = append(results.Keys, hit.Key)
+		results.Scores = append(results.Scores, normalize(hit.Key))
+	}
+	if opts.Highlight != nil {
+		for _, key := range results.Keys {
+			results.Highlights = append(results.Highlights, q.Highlight(bmx, key, *opts.Highlight))
+		}
+	}
+	results.TotalHits, results.TotalHitsRelation = q.totalHits(opts.TrackTotalHitsUpTo, eligible)
+	if len(page) > 0 && len(page) == opts.TopK {
+		next := page[len(page)-1]
+		results.Next = &next
+	}
+	return results
+}
+
+// normalizer returns the function computing the reported score of a
+// document. Min-max and softmax are computed over the matched hits: the
+// allowed documents with a positive score. Unmatched documents score 0.
+func (query *Query) normalizer(normalization ScoreNormalization, allowed func(key string) bool) func(key string) float64 {
+	switch normalization {
+	case NormalizeRaw:
+		return func(key string) float64 { return query.ScoreTable[key] }
+	case NormalizeMinMax, NormalizeSoftmax:
+		lowest, highest := math.Inf(1), math.Inf(-1)
+		for key, score := range query.ScoreTable {
+			if score > 0 && allowed(key) {
+				lowest, highest = min(lowest, score), max(highest, score)
+			}
+		}
+		if normalization == NormalizeMinMax {
+			return func(key string) float64 {
+				score := query.ScoreTable[key]
+				if score <= 0 {
+					return 0
+				}
+				if highest == lowest {
+					return 1
+				}
+				return (score - lowest) / (highest - lowest)
+			}
+		}
+		sum := 0.0
+		for key, score := range query.ScoreTable {
+			if score > 0 && allowed(key) {
+				sum += math.Exp(score - highest)
+			}
+		}
+		return func(key string) float64 {
+			score := query.ScoreTable[key]
+			if score <= 0 {
+				return 0
+			}
+			return math.Exp(score-highest) / sum
+		}
+	default:
+		return func(key string) float64 { return query.NormalizedScoreTable[key] }
+	}
+}
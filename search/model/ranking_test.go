@@ -0,0 +1,149 @@
+package model
+
+import (
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestRanked(t *testing.T) {
+	q := &Query{ScoreTable: map[string]float64{"d": 1, "b": 2, "a": 1, "c": 2, "e": 0.5, "f": 0}}
+	tests := []struct {
+		name  string
+		n     int
+		after *Cursor
+		want  []string
+	}{
+		{"all", 10, nil, []string{"b", "c", "a", "d", "e", "f"}},
+		{"top", 3, nil, []string{"b", "c", "a"}},
+		{"after tie", 2, &Cursor{Score: 2, Key: "b"}, []string{"c", "a"}},
+		{"after score", 10, &Cursor{Score: 1, Key: "d"}, []string{"e", "f"}},
+		{"after last", 10, &Cursor{Score: 0, Key: "f"}, []string{}},
+		{"none", 0, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, hit := range q.ranked(tt.n, tt.after, nil) {
+				got = append(got, hit.Key)
+			}
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ranked(%d, %v) = %v, want %v", tt.n, tt.after, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestPagination checks that offset and cursor pages of a ranking with
+// ties cover the full ranking once, in order.
+func TestPagination(t *testing.T) {
+	docs := make([]string, 10)
+	for i := range docs {
+		docs[i] = "the fox"
+	}
+	docs[3] = "the fox and the fox"
+	docs[7] = "a cat"
+	adapter := newTestAdapter(t, docs...)
+
+	full := adapter.SearchWithOptions("fox", SearchOptions{TopK: 100})
+	if full.TotalHits != 9 || full.TotalHitsRelation != TotalHitsEqual {
+		t.Fatalf("TotalHits = %d %s, want 9 eq", full.TotalHits, full.TotalHitsRelation)
+	}
+	if full.Next != nil {
+		t.Errorf("Next = %v on a partial page, want nil", full.Next)
+	}
+
+	for _, pageSize := range []int{1, 3, 4, 10} {
+		var byOffset, byCursor []string
+		for offset := 0; offset < len(full.Keys); offset += pageSize {
+			page := adapter.SearchWithOptions("fox", SearchOptions{TopK: pageSize, Offset: offset})
+			byOffset = append(byOffset, page.Keys...)
+		}
+		var after *Cursor
+		for range docs {
+			page := adapter.SearchWithOptions("fox", SearchOptions{TopK: pageSize, SearchAfter: after})
+			byCursor = append(byCursor, page.Keys...)
+			if after = page.Next; after == nil {
+				break
+			}
+		}
+		if !slices.Equal(byOffset, full.Keys) {
+			t.Errorf("offset pages of %d = %v, want %v", pageSize, byOffset, full.Keys)
+		}
+		if !slices.Equal(byCursor, full.Keys) {
+			t.Errorf("cursor pages of %d = %v, want %v", pageSize, byCursor, full.Keys)
+		}
+	}
+
+	capped := adapter.SearchWithOptions("fox", SearchOptions{TopK: 2, TrackTotalHitsUpTo: 5})
+	if capped.TotalHits != 5 || capped.TotalHitsRelation != TotalHitsGTE {
+		t.Errorf("capped TotalHits = %d %s, want 5 gte", capped.TotalHits, capped.TotalHitsRelation)
+	}
+}
+
+func TestNormalization(t *testing.T) {
+	adapter := newTestAdapter(t, testDocs...)
+	q := Query{Text: "fox lazy"}
+	q.Initialize(adapter.bmx)
+	for _, tt := range []struct {
+		name          string
+		normalization ScoreNormalization
+		check         func(t *testing.T, results SearchResults)
+	}{
+		{"bmx", NormalizeBMX, func(t *testing.T, results SearchResults) {
+			for i, key := range results.Keys {
+				if results.Scores[i] != q.NormalizedScoreTable[key] {
+					t.Errorf("score of %s = %g, want %g", key, results.Scores[i], q.NormalizedScoreTable[key])
+				}
+			}
+		}},
+		{"raw", NormalizeRaw, func(t *testing.T, results SearchResults) {
+			for i, key := range results.Keys {
+				if results.Scores[i] != q.ScoreTable[key] {
+					t.Errorf("score of %s = %g, want %g", key, results.Scores[i], q.ScoreTable[key])
+				}
+			}
+		}},
+		{"min-max", NormalizeMinMax, func(t *testing.T, results SearchResults) {
+			if first, last := results.Scores[0], results.Scores[results.TotalHits-1]; first != 1 || last != 0 {
+				t.Errorf("best and worst hit scores = %g, %g, want 1, 0", first, last)
+			}
+		}},
+		{"softmax", NormalizeSoftmax, func(t *testing.T, results SearchResults) {
+			sum := 0.0
+			for _, score := range results.Scores {
+				sum += score
+			}
+			if math.Abs(sum-1) > 1e-9 {
+				t.Errorf("scores sum to %g, want 1", sum)
+			}
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			results := adapter.SearchWithOptions("fox lazy", SearchOptions{TopK: 10, Normalization: tt.normalization})
+			if results.TotalHits != 4 {
+				t.Fatalf("TotalHits = %d, want 4", results.TotalHits)
+			}
+			tt.check(t, results)
+
+			// MinScore applies to the reported scores, which do not change.
+			minScore := results.Scores[1]
+			above := adapter.SearchWithOptions("fox lazy", SearchOptions{TopK: 10, Normalization: tt.normalization, MinScore: minScore})
+			want := 0
+			for _, score := range results.Scores {
+				if score >= minScore {
+					want++
+				}
+			}
+			if len(above.Keys) != want || above.TotalHits != want {
+				t.Errorf("MinScore %g kept %d hits (TotalHits %d), want %d", minScore, len(above.Keys), above.TotalHits, want)
+			}
+			if !slices.Equal(above.Scores, results.Scores[:len(above.Scores)]) {
+				t.Errorf("scores above MinScore = %v, want a prefix of %v", above.Scores, results.Scores)
+			}
+		})
+	}
+}
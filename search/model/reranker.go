@@ -0,0 +1,295 @@
+package model
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// RerankDocument is a candidate passed to a Reranker.
+type RerankDocument struct {
+	Key  string
+	Text string
+}
+
+// Reranker scores candidates against a query, higher is more relevant.
+// It returns one score per document, in order.
+type Reranker interface {
+	Rerank(ctx context.Context, query string, docs []RerankDocument) ([]float64, error)
+}
+
+type RerankMode int
+
+const (
+	// RerankPointwise asks the LLM for a relevance grade per document.
+	RerankPointwise RerankMode = iota
+	// RerankListwise asks the LLM to order windows of documents.
+	RerankListwise
+)
+
+// LLMReranker reranks through an LLMClient. Pointwise grading sends
+// BatchSize documents per prompt; listwise ranking slides a window of
+// WindowSize documents from the bottom of the list to the top by Step, so
+// relevant documents can move up across windows. Responses are cached by
+// prompt.
+type LLMReranker struct {
+	Client      *LLMClient
+	Model       string
+	Mode        RerankMode
+	BatchSize   int // Documents per pointwise prompt, defaults to 10
+	WindowSize  int // Documents per listwise window, defaults to 20
+	Step        int // Listwise window step, defaults to WindowSize/2
+	MaxDocChars int // Documents are truncated to this length, defaults to 1000
+
+	mu    sync.Mutex
+	cache map[uint64]string
+}
+
+func NewLLMReranker(config ClientConfig, model string, mode RerankMode) *LLMReranker {
+	return &LLMReranker{Client: NewLLMClient(config), Model: model, Mode: mode}
+}
+
+func (r *LLMReranker) Rerank(ctx context.Context, query string, docs []RerankDocument) ([]float64, error) {
+	if r.Mode == RerankListwise {
+		return r.listwise(ctx, query, docs)
+	}
+	return r.pointwise(ctx, query, docs)
+}
+
+func (r *LLMReranker) pointwise(ctx context.Context, query string, docs []RerankDocument) ([]float64, error) {
+	batchSize := r.BatchSize
+	if batchSize <= 0 {
+		batchSize = 10
+	}
+	scores := make([]float64, 0, len(docs))
+	for start := 0; start < len(docs); start += batchSize {
+		batch := docs[start:min(start+batchSize, len(docs))]
+		prompt := fmt.Sprintf(`Grade how relevant each passage is to the query, from 0 (irrelevant) to 10 (perfectly relevant).
+Answer only with a JSON array of %d numbers, one per passage, in order.
+
+Query: %s
+
+%s`, len(batch), query, r.passages(batch))
+		response, err := r.complete(ctx, prompt)
+		if err != nil {
+			return nil, err
+		}
+		grades, err := parseGrades(response, len(batch))
+		if err != nil {
+			return nil, err
+		}
+		scores = append(scores, grades...)
+	}
+	return scores, nil
+}
+
+func (r *LLMReranker) listwise(ctx context.Context, query string, docs []RerankDocument) ([]float64, error) {
+	windowSize := r.WindowSize
+	if windowSize <= 0 {
+		windowSize = 20
+	}
+	step := r.Step
+	if step <= 0 {
+		step = max(windowSize/2, 1)
+	}
+	order := make([]int, len(docs))
+	for i := range order {
+		order[i] = i
+	}
+	for end := len(docs); end > 0; end -= step {
+		start := max(end-windowSize, 0)
+		window := make([]RerankDocument, end-start)
+		for i, index := range order[start:end] {
+			window[i] = docs[index]
+		}
+		prompt := fmt.Sprintf(`Rank the %d passages below by relevance to the query, most relevant first.
+Answer only with the passage numbers, like [2] > [1] > [3].
+
+Query: %s
+
+%s`, len(window), query, r.passages(window))
+		response, err := r.complete(ctx, prompt)
+		if err != nil {
+			return nil, err
+		}
+		permuted := make([]int, 0, len(window))
+		for _, i := range parsePermutation(response, len(window)) {
+			permuted = append(permuted, order[start+i])
+		}
+		copy(order[start:end], permuted)
+		if start == 0 {
+			break
+		}
+	}
+	scores := make([]float64, len(docs))
+	for rank, index := range order {
+		scores[index] = float64(len(docs) - rank)
+	}
+	return scores, nil
+}
+
+func (r *LLMReranker) passages(docs []RerankDocument) string {
+	maxChars := r.MaxDocChars
+	if maxChars <= 0 {
+		maxChars = 1000
+	}
+	var sb strings.Builder
+	for i, doc := range docs {
+		text := strings.Join(strings.Fields(doc.Text), " ")
+		if runes := []rune(text); len(runes) > maxChars {
+			text = string(runes[:maxChars])
+		}
+		fmt.Fprintf(&sb, "[%d] %s\n", i+1, text)
+	}
+	return sb.String()
+}
+
+func (r *LLMReranker) complete(ctx context.Context, prompt string) (string, error) {
+	h := fnv.New64a()
+	h.Write([]byte(r.Model + "\x00" + prompt))
+	key := h.Sum64()
+
+	r.mu.Lock()
+	response, ok := r.cache[key]
+	r.mu.Unlock()
+	if ok {
+		return response, nil
+	}
+
+	response, err := r.Client.CompletionText(ctx, ChatCompletionRequest{
+		Models:   []string{r.Model},
+		Messages: []ConvMessage{{Role: "user", Content: prompt}},
+	})
+	if err != nil {
+		return "", err
+	}
+	r.mu.Lock()
+	if r.cache == nil {
+		r.cache = map[uint64]string{}
+	}
+	r.cache[key] = response
+	r.mu.Unlock()
+	return response, nil
+}
+
+// parseGrades reads the JSON array of grades from an LLM response.
+func parseGrades(response string, n int) ([]float64, error) {
+	start, end := strings.Index(response, "["), strings.LastIndex(response, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("no grades in rerank response: %q", response)
+	}
+	var grades []float64
+	if err := json.Unmarshal([]byte(response[start:end+1]), &grades); err != nil {
+		return nil, fmt.Errorf("error parsing rerank response: %v", err)
+	}
+	if len(grades) != n {
+		return nil, fmt.Errorf("expected %d grades, got %d", n, len(grades))
+	}
+	return grades, nil
+}
+
+var passageNumber = regexp.MustCompile(`\d+`)
+
+// parsePermutation reads passage numbers from an LLM response and returns
+// a permutation of [0, n): unknown and repeated numbers are skipped and
+// missing passages keep their relative order at the end.
+func parsePermutation(response string, n int) []int {
+	seen := make([]bool, n)
+	permutation := make([]int, 0, n)
+	for _, match := range passageNumber.FindAllString(response, -1) {
+		i, err := strconv.Atoi(match)
+		if err != nil || i < 1 || i > n || seen[i-1] {
+			continue
+		}
+		seen[i-1] = true
+		permutation = append(permutation, i-1)
+	}
+	for i := range seen {
+		if !seen[i] {
+			permutation = append(permutation, i)
+		}
+	}
+	return permutation
+}
+
+// FakeReranker is a deterministic local reranker for tests: it scores a
+// document by the fraction of distinct query words it contains.
+type FakeReranker struct{}
+
+func (FakeReranker) Rerank(ctx context.Context, query string, docs []RerankDocument) ([]float64, error) {
+	queryWords := rerankWords(query)
+	scores := make([]float64, len(docs))
+	if len(queryWords) == 0 {
+		return scores, nil
+	}
+	for i, doc := range docs {
+		docWords := rerankWords(doc.Text)
+		for word := range queryWords {
+			if docWords[word] {
+				scores[i]++
+			}
+		}
+		scores[i] /= float64(len(queryWords))
+	}
+	return scores, nil
+}
+
+func rerankWords(text string) map[string]bool {
+	words := map[string]bool{}
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		words[word] = true
+	}
+	return words
+}
+
+// Rerank reorders the first topN results (all when topN <= 0) by the
+// scores of reranker and drops the rest. Facets and total hits are kept.
+func (adapter *BMXAdapter) Rerank(ctx context.Context, query string, results SearchResults, reranker Reranker, topN int) (SearchResults, error) {
+	n := len(results.Keys)
+	if topN > 0 {
+		n = min(n, topN)
+	}
+	docs := make([]RerankDocument, n)
+	for i, key := range results.Keys[:n] {
+		docs[i] = RerankDocument{Key: key, Text: adapter.bmx.Docs[key].Text}
+	}
+	scores, err := reranker.Rerank(ctx, query, docs)
+	if err != nil {
+		return SearchResults{}, err
+	}
+	if len(scores) != n {
+		return SearchResults{}, fmt.Errorf("reranker returned %d scores for %d documents", len(scores), n)
+	}
+
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	// Ties keep the retrieval order.
+	sort.SliceStable(order, func(i, j int) bool { return scores[order[i]] > scores[order[j]] })
+
+	reranked := SearchResults{
+		Keys:              make([]string, n),
+		Scores:            make([]float64, n),
+		Facets:            results.Facets,
+		TotalHits:         results.TotalHits,
+		TotalHitsRelation: results.TotalHitsRelation,
+	}
+	for rank, i := range order {
+		reranked.Keys[rank] = results.Keys[i]
+		reranked.Scores[rank] = scores[i]
+		if results.Highlights != nil {
+			reranked.Highlights = append(reranked.Highlights, results.Highlights[i])
+		}
+	}
+	return reranked, nil
+}
@@ -0,0 +1,97 @@
+package model
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestParsePermutation(t *testing.T) {
+	tests := []struct {
+		response string
+		n        int
+		want     []int
+	}{
+		{"[3] > [1] > [2]", 3, []int{2, 0, 1}},
+		{"2, 2, 7, 0, 1", 3, []int{1, 0, 2}},
+		{"no ranking", 3, []int{0, 1, 2}},
+		{"[4] > [2]", 4, []int{3, 1, 0, 2}},
+	}
+	for _, tt := range tests {
+		if got := parsePermutation(tt.response, tt.n); !slices.Equal(got, tt.want) {
+			t.Errorf("parsePermutation(%q, %d) = %v, want %v", tt.response, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestParseGrades(t *testing.T) {
+	tests := []struct {
+		response string
+		n        int
+		want     []float64
+		wantErr  bool
+	}{
+		{"[3, 0, 1.5]", 3, []float64{3, 0, 1.5}, false},
+		{"Grades: [2, 1] done", 2, []float64{2, 1}, false},
+		{"[2, 1]", 3, nil, true},
+		{"none", 1, nil, true},
+		{"[a]", 1, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseGrades(tt.response, tt.n)
+		if (err != nil) != tt.wantErr || !slices.Equal(got, tt.want) {
+			t.Errorf("parseGrades(%q, %d) = %v, %v", tt.response, tt.n, got, err)
+		}
+	}
+}
+
+func TestFakeReranker(t *testing.T) {
+	docs := []RerankDocument{{Key: "a", Text: "A red fox."}, {Key: "b", Text: "The lazy dog"}, {Key: "c", Text: "fox and dog"}}
+	scores, err := FakeReranker{}.Rerank(context.Background(), "Fox dog", docs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []float64{0.5, 0.5, 1}; !slices.Equal(scores, want) {
+		t.Errorf("FakeReranker scores = %v, want %v", scores, want)
+	}
+}
+
+// stubReranker returns fixed scores.
+type stubReranker []float64
+
+func (r stubReranker) Rerank(ctx context.Context, query string, docs []RerankDocument) ([]float64, error) {
+	return r, nil
+}
+
+func TestAdapterRerank(t *testing.T) {
+	adapter := newTestAdapter(t, testDocs...)
+	results := SearchResults{Keys: []string{"a", "b", "c", "f"}, Scores: []float64{4, 3, 2, 1}, TotalHits: 7}
+	tests := []struct {
+		name     string
+		query    string
+		reranker Reranker
+		topN     int
+		want     []string
+	}{
+		{"fake", "lazy cat", FakeReranker{}, 0, []string{"c", "a", "b", "f"}},
+		{"top n", "lazy cat", FakeReranker{}, 2, []string{"a", "b"}},
+		{"ties keep order", "", stubReranker{1, 2, 1, 2}, 0, []string{"b", "f", "a", "c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reranked, err := adapter.Rerank(context.Background(), tt.query, results, tt.reranker, tt.topN)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(reranked.Keys, tt.want) {
+				t.Errorf("Rerank = %v, want %v", reranked.Keys, tt.want)
+			}
+			if reranked.TotalHits != results.TotalHits {
+				t.Errorf("TotalHits = %d, want %d", reranked.TotalHits, results.TotalHits)
+			}
+		})
+	}
+	if _, err := adapter.Rerank(context.Background(), "fox", results, stubReranker{1}, 0); err == nil {
+		t.Error("Rerank with too few scores succeeded, want an error")
+	}
+}
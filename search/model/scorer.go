@@ -0,0 +1,202 @@
+package model
+
+import (
+	"errors"
+	"math"
+	"strings"
+)
+
+// Scorer computes a score for every document of the index from the shared
+// index statistics (F tables, NumAppearances, IDF table, entropies).
+// Explain must break down the same computation as Score for one document.
+type Scorer interface {
+	Name() string
+	Score(query *Query, bmx *BMX) map[string]float64
+	Explain(query *Query, bmx *BMX, docID string) Explanation
+}
+
+// MaxScorer is implemented by scorers with a theoretical max score, which
+// the normalised scores divide by. Scorers without it are normalised by the
+// BMX max.
+type MaxScorer interface {
+	MaxScore(query *Query, bmx *BMX) float64
+}
+
+// BMXScorer is the entropy-weighted BMX scoring function.
+type BMXScorer struct{}
+
+// BM25Scorer is the classic Okapi BM25 scoring function.
+type BM25Scorer struct {
+	K1 float64
+	B  float64
+}
+
+// BM25PlusScorer is BM25+ (Lv & Zhai, 2011), which adds a lower bound Delta
+// to the term frequency normalisation so long documents are not over-penalised.
+type BM25PlusScorer struct {
+	K1    float64
+	B     float64
+	Delta float64
+}
+
+// BM25LScorer is BM25L (Lv & Zhai, 2011), which shifts the length-normalised
+// term frequency by Delta.
+type BM25LScorer struct {
+	K1    float64
+	B     float64
+	Delta float64
+}
+
+func NewBM25Scorer(k1, b float64) BM25Scorer {
+	return BM25Scorer{K1: k1, B: b}
+}
+
+func NewBM25PlusScorer(k1, b, delta float64) BM25PlusScorer {
+	return BM25PlusScorer{K1: k1, B: b, Delta: delta}
+}
+
+func NewBM25LScorer(k1, b, delta float64) BM25LScorer {
+	return BM25LScorer{K1: k1, B: b, Delta: delta}
+}
+
+// scorersDict maps scorer names to scorers with their usual default parameters.
+var scorersDict = map[string]Scorer{
+	"bmx":   BMXScorer{},
+	"bm25":  NewBM25Scorer(1.2, 0.75),
+	"bm25+": NewBM25PlusScorer(1.2, 0.75, 1.0),
+	"bm25l": NewBM25LScorer(1.2, 0.75, 0.5),
+}
+
+// GetScorer retrieves a scorer by name.
+func GetScorer(scorer string) (Scorer, error) {
+	scorer = strings.ToLower(scorer)
+	if s, exists := scorersDict[scorer]; exists {
+		return s, nil
+	}
+	return nil, errors.New("scorer " + scorer + " not supported")
+}
+
+func (BMXScorer) Name() string      { return "bmx" }
+func (BM25Scorer) Name() string     { return "bm25" }
+func (BM25PlusScorer) Name() string { return "bm25+" }
+func (BM25LScorer) Name() string    { return "bm25l" }
+
+// maxIDF is the idf of a token found in a single document.
+func maxIDF(bmx *BMX) float64 {
+	return math.Log(1 + (float64(bmx.Params.N)-0.5)/1.5)
+}
+
+func (BMXScorer) MaxScore(query *Query, bmx *BMX) float64 {
+	return query.TotalWeight * (maxIDF(bmx) + 1.0)
+}
+
+func (s BM25Scorer) MaxScore(query *Query, bmx *BMX) float64 {
+	s.K1, s.B = query.bm25Params(bmx, s.K1, s.B)
+	return query.TotalWeight * maxIDF(bmx) * (s.K1 + 1)
+}
+
+func (s BM25PlusScorer) MaxScore(query *Query, bmx *BMX) float64 {
+	s.K1, s.B = query.bm25Params(bmx, s.K1, s.B)
+	return query.TotalWeight * maxIDF(bmx) * (s.K1 + 1 + s.Delta)
+}
+
+func (s BM25LScorer) MaxScore(query *Query, bmx *BMX) float64 {
+	s.K1, s.B = query.bm25Params(bmx, s.K1, s.B)
+	return query.TotalWeight * maxIDF(bmx) * (s.K1 + 1)
+}
+
+func (BMXScorer) Score(query *Query, bmx *BMX) map[string]float64 {
+	scores := make(map[string]float64, len(bmx.Docs))
+	for doc_key := range bmx.Docs {
+		scores[doc_key] = 0.0
+	}
+	params := query.params(bmx)
+	invE_tilde := 1.0 / query.max_E_tilde
+	invAvgdl := 1.0 / params.Avgdl
+	alpha1 := params.Alpha + 1.0
+	for qi := range query.Tokens {
+		idf := bmx.IDF_table[qi]
+		e := bmx.E_tilde_table[qi] * invE_tilde
+		alphaAverageEntropy := params.Alpha * query.avgEntropy
+		betaE := params.Beta * e
+		for _, doc_key := range bmx.NumAppearances[qi] {
+			f := bmx.Docs[doc_key].F_table[qi]
+			s := query.S_table[doc_key]
+			scores[doc_key] += query.Tokens[qi] * (idf*(float64(f)*alpha1/(float64(f)+params.Alpha*(float64(len(bmx.Docs[doc_key].Tokens))*invAvgdl)+alphaAverageEntropy)) + betaE*s)
+		}
+	}
+	return scores
+}
+
+func (s BM25Scorer) Score(query *Query, bmx *BMX) map[string]float64 {
+	scores := make(map[string]float64, len(bmx.Docs))
+	for doc_key := range bmx.Docs {
+		scores[doc_key] = 0.0
+	}
+	s.K1, s.B = query.bm25Params(bmx, s.K1, s.B)
+	invAvgdl := 1.0 / bmx.Params.Avgdl
+	for qi, weight := range query.Tokens {
+		idf := bmx.IDF_table[qi]
+		for _, doc_key := range bmx.NumAppearances[qi] {
+			f := float64(bmx.Docs[doc_key].F_table[qi])
+			norm := 1 - s.B + s.B*float64(len(bmx.Docs[doc_key].Tokens))*invAvgdl
+			scores[doc_key] += weight * idf * f * (s.K1 + 1) / (f + s.K1*norm)
+		}
+	}
+	return scores
+}
+
+func (s BM25PlusScorer) Score(query *Query, bmx *BMX) map[string]float64 {
+	scores := make(map[string]float64, len(bmx.Docs))
+	for doc_key := range bmx.Docs {
+		scores[doc_key] = 0.0
+	}
+	s.K1, s.B = query.bm25Params(bmx, s.K1, s.B)
+	invAvgdl := 1.0 / bmx.Params.Avgdl
+	for qi, weight := range query.Tokens {
+		idf := bmx.IDF_table[qi]
+		for _, doc_key := range bmx.NumAppearances[qi] {
+			f := float64(bmx.Docs[doc_key].F_table[qi])
+			norm := 1 - s.B + s.B*float64(len(bmx.Docs[doc_key].Tokens))*invAvgdl
+			scores[doc_key] += weight * idf * (f*(s.K1+1)/(f+s.K1*norm) + s.Delta)
+		}
+	}
+	return scores
+}
+
+func (s BM25LScorer) Score(query *Query, bmx *BMX) map[string]float64 {
+	scores := make(map[string]float64, len(bmx.Docs))
+	for doc_key := range bmx.Docs {
+		scores[doc_key] = 0.0
+	}
+	s.K1, s.B = query.bm25Params(bmx, s.K1, s.B)
+	invAvgdl := 1.0 / bmx.Params.Avgdl
+	for qi, weight := range query.Tokens {
+		idf := bmx.IDF_table[qi]
+		for _, doc_key := range bmx.NumAppearances[qi] {
+			f := float64(bmx.Docs[doc_key].F_table[qi])
+			ctd := f / (1 - s.B + s.B*float64(len(bmx.Docs[doc_key].Tokens))*invAvgdl)
+			scores[doc_key] += weight * idf * (s.K1 + 1) * (ctd + s.Delta) / (s.K1 + ctd + s.Delta)
+		}
+	}
+	return scores
+}
+
+// scorer returns the scorer to use for the query: the query's own scorer,
+// then BMXF when field boosts are given (the index default when it is a
+// BMXF scorer, so its weights apply), then the index default, then BMX.
+func (query *Query) scorer(bmx *BMX) Scorer {
+	if query.Scorer != nil {
+		return query.Scorer
+	}
+	if len(query.FieldBoosts) > 0 {
+		if fielded, ok := bmx.Scorer.(BMXFScorer); ok {
+			return fielded
+		}
+		return BMXFScorer{}
+	}
+	if bmx.Scorer != nil {
+		return bmx.Scorer
+	}
+	return BMXScorer{}
+}
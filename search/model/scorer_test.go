@@ -0,0 +1,85 @@
+package model
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGetScorer(t *testing.T) {
+	for _, name := range []string{"bmx", "BM25", "bm25+", "bm25l"} {
+		if _, err := GetScorer(name); err != nil {
+			t.Errorf("GetScorer(%q): %v", name, err)
+		}
+	}
+	if _, err := GetScorer("tfidf"); err == nil {
+		t.Error("GetScorer(tfidf) succeeded, want an error")
+	}
+}
+
+func TestBM25Score(t *testing.T) {
+	adapter := newTestAdapter(t, testDocs...)
+	scorer := NewBM25Scorer(1.2, 0.75)
+	q := Query{Text: "lazy dog", Scorer: scorer}
+	q.Initialize(adapter.bmx)
+	for key, doc := range adapter.bmx.Docs {
+		want := 0.0
+		for _, token := range []string{"lazy", "dog"} {
+			f := float64(doc.F_table[token])
+			n := float64(len(adapter.bmx.NumAppearances[token]))
+			idf := math.Log((float64(len(testDocs))-n+0.5)/(n+0.5) + 1)
+			norm := 1 - scorer.B + scorer.B*float64(len(doc.Tokens))/adapter.bmx.Params.Avgdl
+			want += idf * f * (scorer.K1 + 1) / (f + scorer.K1*norm)
+		}
+		if math.Abs(q.ScoreTable[key]-want) > 1e-9 {
+			t.Errorf("BM25 score of %s = %g, want %g", key, q.ScoreTable[key], want)
+		}
+	}
+}
+
+// TestNormalizedScores checks that every scorer's normalised scores lie in
+// [0, 1], whatever the scorer.
+func TestNormalizedScores(t *testing.T) {
+	adapter := newTestAdapter(t, testDocs...)
+	scorers := []Scorer{BMXScorer{}, NewBM25Scorer(1.2, 0.75), NewBM25PlusScorer(1.2, 0.75, 1), NewBM25LScorer(1.2, 0.75, 0.5)}
+	for _, scorer := range scorers {
+		t.Run(scorer.Name(), func(t *testing.T) {
+			q := Query{Text: "lazy dog fox", Scorer: scorer}
+			q.Initialize(adapter.bmx)
+			best := 0.0
+			for key, score := range q.NormalizedScoreTable {
+				if score < 0 || score > 1 {
+					t.Errorf("normalised score of %s = %g, want within [0, 1]", key, score)
+				}
+				best = max(best, score)
+			}
+			if best == 0 {
+				t.Error("no document has a positive normalised score")
+			}
+		})
+	}
+}
+
+func TestBM25ParamOverrides(t *testing.T) {
+	adapter := newTestAdapter(t, testDocs...)
+	k1, b := 2.0, 0.3
+	scorers := []struct {
+		scorer, pinned Scorer
+	}{
+		{NewBM25Scorer(1.2, 0.75), NewBM25Scorer(k1, b)},
+		{NewBM25PlusScorer(1.2, 0.75, 1), NewBM25PlusScorer(k1, b, 1)},
+		{NewBM25LScorer(1.2, 0.75, 0.5), NewBM25LScorer(k1, b, 0.5)},
+	}
+	for _, tt := range scorers {
+		t.Run(tt.scorer.Name(), func(t *testing.T) {
+			overridden := Query{Text: "lazy dog", Scorer: tt.scorer, Overrides: ParamOverrides{K1: &k1, B: &b}}
+			overridden.Initialize(adapter.bmx)
+			pinned := Query{Text: "lazy dog", Scorer: tt.pinned}
+			pinned.Initialize(adapter.bmx)
+			for key, score := range pinned.ScoreTable {
+				if math.Abs(overridden.ScoreTable[key]-score) > 1e-12 {
+					t.Errorf("score of %s with overrides = %g, want %g", key, overridden.ScoreTable[key], score)
+				}
+			}
+		})
+	}
+}
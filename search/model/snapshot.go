@@ -0,0 +1,145 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"BMXGo/search/text_preprocessor"
+)
+
+// snapshotVersion is the version of the snapshot format written by Save.
+const snapshotVersion = 1
+
+// indexSnapshot is the serialised form of an index. Only the analysed
+// documents are stored: every table is recomputed on Load.
+type indexSnapshot struct {
+	Version        int                                   `json:"version"`
+	Name           string                                `json:"name"`
+	Analyzer       *text_preprocessor.AnalyzerDefinition `json:"analyzer,omitempty"`
+	Config         *text_preprocessor.ConfigDefinition   `json:"config,omitempty"` // When the analyzer has no definition
+	StorePositions bool                                  `json:"store_positions,omitempty"`
+	Overrides      ParamOverrides                        `json:"overrides"`
+	Docs           map[string]documentSnapshot           `json:"docs"`
+}
+
+type documentSnapshot struct {
+	Text     string                   `json:"text"`
+	Tokens   []string                 `json:"tokens"`
+	Fields   map[string]fieldSnapshot `json:"fields,omitempty"`
+	Metadata Metadata                 `json:"metadata,omitempty"`
+	Vector   []float32                `json:"vector,omitempty"`
+}
+
+type fieldSnapshot struct {
+	Text   string   `json:"text"`
+	Tokens []string `json:"tokens"`
+}
+
+// Save writes the documents, tokens, analysis settings and parameters of
+// the index as JSON, so that Load analyses queries like the indexed
+// documents. A named analyzer (NewAnalyzerConfig) is stored whole; for a
+// chain built from a Config, only the fields of its ConfigDefinition are,
+// the tokenizer and stemmer functions being those of the config given to
+// Load. The scorer and embedder are not stored, and vectors are reloaded
+// into a BruteForceIndex.
+func (adapter *BMXAdapter) Save(w io.Writer) error {
+	snapshot := indexSnapshot{
+		Version:        snapshotVersion,
+		Name:           adapter.indexName,
+		StorePositions: adapter.bmx.StorePositions,
+		Overrides:      adapter.bmx.Overrides,
+		Docs:           make(map[string]documentSnapshot, len(adapter.bmx.Docs)),
+	}
+	if def, ok := adapter.bmx.TextPreprocessor.Analyzer().Definition(); ok {
+		snapshot.Analyzer = &def
+	} else {
+		config := adapter.bmx.TextPreprocessor.Config().Definition()
+		snapshot.Config = &config
+	}
+	for key, doc := range adapter.bmx.Docs {
+		var fields map[string]fieldSnapshot
+		for name, field := range doc.Fields {
+			if fields == nil {
+				fields = make(map[string]fieldSnapshot, len(doc.Fields))
+			}
+			fields[name] = fieldSnapshot{Text: field.Text, Tokens: field.Tokens}
+		}
+		snapshot.Docs[key] = documentSnapshot{
+			Text:     doc.Text,
+			Tokens:   doc.Tokens,
+			Fields:   fields,
+			Metadata: doc.Metadata,
+			Vector:   doc.Vector,
+		}
+	}
+	return json.NewEncoder(w).Encode(snapshot)
+}
+
+// Load reads an index written by Save. A stored analyzer replaces the
+// analysis chain of config and is registered under its name when no
+// analyzer of that name exists; custom filters and tokenizers it refers to
+// must be registered before loading. Otherwise the stored settings are
+// applied to config, which must have the Tokenizer and Stemmer the index
+// was built with, e.g. from the same NewConfig call.
+func Load(r io.Reader, config text_preprocessor.Config) (BMXAdapter, error) {
+	var snapshot indexSnapshot
+	if err := json.NewDecoder(r).Decode(&snapshot); err != nil {
+		return BMXAdapter{}, fmt.Errorf("error reading index snapshot: %w", err)
+	}
+	if snapshot.Version != snapshotVersion {
+		return BMXAdapter{}, fmt.Errorf("unsupported index snapshot version %d", snapshot.Version)
+	}
+	switch {
+	case snapshot.Analyzer != nil:
+		analyzer, err := text_preprocessor.NewAnalyzer(*snapshot.Analyzer)
+		if err != nil {
+			return BMXAdapter{}, fmt.Errorf("error loading analyzer: %w", err)
+		}
+		if _, ok := text_preprocessor.GetAnalyzerDefinition(snapshot.Analyzer.Name); !ok && snapshot.Analyzer.Name != "" {
+			if err := text_preprocessor.RegisterAnalyzer(*snapshot.Analyzer); err != nil {
+				return BMXAdapter{}, err
+			}
+		}
+		config.Analyzer = analyzer
+	case snapshot.Config != nil:
+		if config.Tokenizer == nil {
+			return BMXAdapter{}, fmt.Errorf("index snapshot has no analyzer and config has no tokenizer")
+		}
+		if err := snapshot.Config.Apply(&config); err != nil {
+			return BMXAdapter{}, fmt.Errorf("error loading config: %w", err)
+		}
+		config.Analyzer = nil
+	default:
+		return BMXAdapter{}, fmt.Errorf("index snapshot has no analysis settings")
+	}
+
+	adapter := Build(snapshot.Name, config)
+	adapter.bmx.StorePositions = snapshot.StorePositions
+	adapter.bmx.Overrides = snapshot.Overrides
+	var ids []string
+	var vectors [][]float32
+	for key, doc := range snapshot.Docs {
+		document := Document{Text: doc.Text, Tokens: doc.Tokens, Metadata: doc.Metadata}
+		for name, field := range doc.Fields {
+			if document.Fields == nil {
+				document.Fields = make(map[string]Field, len(doc.Fields))
+			}
+			document.Fields[name] = Field{Text: field.Text, Tokens: field.Tokens}
+		}
+		adapter.bmx.Docs[key] = document
+		if doc.Vector != nil {
+			ids = append(ids, key)
+			vectors = append(vectors, doc.Vector)
+		}
+	}
+	if len(adapter.bmx.Docs) > 0 {
+		adapter.bmx.FillTables()
+	}
+	if len(ids) > 0 {
+		if err := adapter.AddVectors(ids, vectors); err != nil {
+			return BMXAdapter{}, err
+		}
+	}
+	return adapter, nil
+}
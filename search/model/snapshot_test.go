@@ -0,0 +1,139 @@
+package model
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"BMXGo/search/text_preprocessor"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	adapter := newTestAdapter(t, testDocs...)
+	err := adapter.AddManyWithMetadata([]string{"g", "h"}, []string{"a fox in the snow", "the lazy fox sleeps"}, []Metadata{
+		{"tags": KeywordAttr("winter", "fox"), "published": DateAttr(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC))},
+		{"tags": KeywordAttr("fox"), "rating": NumericAttr(4.5)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = adapter.AddDocuments([]FieldedDocument{{ID: "i", Fields: map[string]string{"title": "Fox", "body": "a fox and a dog"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := adapter.AddVectors([]string{"a", "b"}, [][]float32{{1, 0}, {0, 1}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := adapter.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(&buf, text_preprocessor.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(loaded.bmx.Docs) != len(adapter.bmx.Docs) {
+		t.Errorf("%d documents loaded, want %d", len(loaded.bmx.Docs), len(adapter.bmx.Docs))
+	}
+	if loaded.bmx.Vectors == nil || loaded.bmx.Vectors.Len() != 2 {
+		t.Errorf("vectors not loaded")
+	}
+	searches := []struct {
+		query string
+		opts  SearchOptions
+	}{
+		{"lazy fox", SearchOptions{TopK: 10}},
+		{`"lazy fox"`, SearchOptions{TopK: 10}},
+		{"fox", SearchOptions{TopK: 10, Filter: KeywordFilter("tags", "winter")}},
+		{"fox", SearchOptions{TopK: 10, Filter: NumericRangeFilter("rating", 4, 5)}},
+		{"fox", SearchOptions{TopK: 10, FieldBoosts: map[string]float64{"title": 2}}},
+	}
+	for _, search := range searches {
+		want, err := adapter.SearchQuery(search.query, search.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(want.Keys) == 0 {
+			t.Fatalf("SearchQuery(%q) found nothing before Save", search.query)
+		}
+		got, err := loaded.SearchQuery(search.query, search.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(got.Keys, want.Keys) || !slices.Equal(got.Scores, want.Scores) {
+			t.Errorf("SearchQuery(%q) after Load = %v %v, want %v %v", search.query, got.Keys, got.Scores, want.Keys, want.Scores)
+		}
+	}
+}
+
+// TestSnapshotConfig checks that an index built from a Config is saved with
+// its settings, applied on Load to a config with the same functions.
+func TestSnapshotConfig(t *testing.T) {
+	config, err := text_preprocessor.NewConfig("word", "english", "english")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.KeepDiacritics = true
+	adapter := Build("test", *config)
+	if err := adapter.bmx.TextPreprocessor.SetStopwords([]string{"quick"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := adapter.AddMany([]string{"a", "b", "c"}, []string{"the quick brown fox", "a lazy dog", "foxes in a café"}); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := adapter.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	snapshot := buf.String()
+
+	fresh, err := text_preprocessor.NewConfig("word", "english", "english")
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(strings.NewReader(snapshot), *fresh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := loaded.bmx.TextPreprocessor.Config()
+	if !got.KeepDiacritics {
+		t.Error("loaded config folds diacritics")
+	}
+	for _, query := range []string{"quick", "the fox", "foxes", "café"} {
+		want, results := adapter.Search(query, 10), loaded.Search(query, 10)
+		if query != "quick" && want.TotalHits == 0 {
+			t.Errorf("Search(%q) found nothing before Save", query)
+		}
+		if !slices.Equal(results.Keys, want.Keys) || !slices.Equal(results.Scores, want.Scores) {
+			t.Errorf("Search(%q) after Load = %v %v, want %v %v", query, results.Keys, results.Scores, want.Keys, want.Scores)
+		}
+	}
+	if results := loaded.Search("quick", 10); results.TotalHits != 0 {
+		t.Errorf("Search(quick) found %q, want the custom stopword removed", results.Keys)
+	}
+
+	if _, err := Load(strings.NewReader(snapshot), text_preprocessor.Config{}); err == nil {
+		t.Error("Load of a config snapshot without tokenizer succeeded, want an error")
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		snapshot string
+	}{
+		{"invalid json", `{"version":`},
+		{"unknown version", `{"version":99,"docs":{}}`},
+		{"no analyzer", `{"version":1,"docs":{}}`},
+		{"unknown tokenizer", `{"version":1,"analyzer":{"name":"x","tokenizer":"nope"},"docs":{}}`},
+	}
+	for _, tt := range tests {
+		if _, err := Load(strings.NewReader(tt.snapshot), text_preprocessor.Config{}); err == nil {
+			t.Errorf("Load with %s succeeded, want an error", tt.name)
+		}
+	}
+}
@@ -0,0 +1,350 @@
+package model
+
+import (
+	"sort"
+	"strings"
+)
+
+// TermDictionary is a trie over the index vocabulary supporting prefix,
+// wildcard and fuzzy (Levenshtein) lookups.
+type TermDictionary struct {
+	root trieNode
+	size int
+}
+
+type trieNode struct {
+	labels   []rune // Sorted, so lookups return terms in lexicographic order
+	children []*trieNode
+	term     string
+	isTerm   bool
+}
+
+// FuzzyMatch is a vocabulary term within the edit distance of a lookup.
+type FuzzyMatch struct {
+	Term     string
+	Distance int
+}
+
+func NewTermDictionary(terms []string) *TermDictionary {
+	sorted := append([]string(nil), terms...)
+	sort.Strings(sorted)
+	d := &TermDictionary{}
+	for _, term := range sorted {
+		d.insert(term)
+	}
+	return d
+}
+
+func (d *TermDictionary) insert(term string) {
+	node := &d.root
+	for _, r := range term {
+		child := node.child(r)
+		if child == nil {
+			// Terms are inserted in sorted order, so labels stay sorted.
+			child = &trieNode{}
+			node.labels = append(node.labels, r)
+			node.children = append(node.children, child)
+		}
+		node = child
+	}
+	if !node.isTerm {
+		node.isTerm = true
+		node.term = term
+		d.size++
+	}
+}
+
+func (n *trieNode) child(r rune) *trieNode {
+	i := sort.Search(len(n.labels), func(i int) bool { return n.labels[i] >= r })
+	if i < len(n.labels) && n.labels[i] == r {
+		return n.children[i]
+	}
+	return nil
+}
+
+func (d *TermDictionary) Len() int {
+	return d.size
+}
+
+func (d *TermDictionary) Contains(term string) bool {
+	node := &d.root
+	for _, r := range term {
+		if node = node.child(r); node == nil {
+			return false
+		}
+	}
+	return node.isTerm
+}
+
+// collect appends the terms under n, stopping at limit terms (0 for no limit).
+func (n *trieNode) collect(terms []string, limit int) []string {
+	if limit > 0 && len(terms) >= limit {
+		return terms
+	}
+	if n.isTerm {
+		terms = append(terms, n.term)
+	}
+	for _, child := range n.children {
+		terms = child.collect(terms, limit)
+	}
+	return terms
+}
+
+// Prefix returns the terms starting with prefix.
+func (d *TermDictionary) Prefix(prefix string, limit int) []string {
+	node := &d.root
+	for _, r := range prefix {
+		if node = node.child(r); node == nil {
+			return nil
+		}
+	}
+	return node.collect(nil, limit)
+}
+
+// Wildcard returns up to limit terms (0 for no limit) matching pattern,
+// where * matches any sequence of characters and ? exactly one. Each trie
+// node is visited at most once per pattern position, and the walk stops
+// once limit terms are found.
+func (d *TermDictionary) Wildcard(pattern string, limit int) []string {
+	if !strings.ContainsAny(pattern, "*?") {
+		if d.Contains(pattern) {
+			return []string{pattern}
+		}
+		return nil
+	}
+	w := wildcardWalk{pattern: []rune(pattern), limit: limit, visited: map[wildcardState]bool{}}
+	w.match(&d.root, 0)
+	sort.Strings(w.terms)
+	return w.terms
+}
+
+// wildcardState is a trie node reached at a pattern position, -1 when all
+// the terms under the node match.
+type wildcardState struct {
+	node *trieNode
+	pos  int
+}
+
+type wildcardWalk struct {
+	pattern []rune
+	limit   int
+	visited map[wildcardState]bool
+	terms   []string
+}
+
+func (w *wildcardWalk) done() bool {
+	return w.limit > 0 && len(w.terms) >= w.limit
+}
+
+// add appends the term of n, which a term reached through several
+// expansions of the stars only gets once.
+func (w *wildcardWalk) add(n *trieNode) {
+	if n.isTerm && !w.visited[wildcardState{n, len(w.pattern)}] && !w.visited[wildcardState{n, -1}] {
+		w.terms = append(w.terms, n.term)
+	}
+}
+
+func (w *wildcardWalk) match(n *trieNode, pos int) {
+	state := wildcardState{n, pos}
+	if w.done() || w.visited[state] {
+		return
+	}
+	if pos == len(w.pattern) {
+		w.add(n)
+		w.visited[state] = true
+		return
+	}
+	w.visited[state] = true
+	switch w.pattern[pos] {
+	case '*':
+		rest := pos + 1
+		for rest < len(w.pattern) && w.pattern[rest] == '*' {
+			rest++
+		}
+		if rest == len(w.pattern) {
+			w.collect(n)
+			return
+		}
+		// Either the star matches nothing here, or it consumes one character.
+		w.match(n, rest)
+		for _, child := range n.children {
+			w.match(child, pos)
+		}
+	case '?':
+		for _, child := range n.children {
+			w.match(child, pos+1)
+		}
+	default:
+		if child := n.child(w.pattern[pos]); child != nil {
+			w.match(child, pos+1)
+		}
+	}
+}
+
+// collect adds the terms under n.
+func (w *wildcardWalk) collect(n *trieNode) {
+	state := wildcardState{n, -1}
+	if w.done() || w.visited[state] {
+		return
+	}
+	w.add(n)
+	w.visited[state] = true
+	for _, child := range n.children {
+		w.collect(child)
+	}
+}
+
+// Fuzzy returns the terms within maxEdits insertions, deletions or
+// substitutions of term, closest first. The trie is walked with one row of
+// the Levenshtein matrix per node, which prunes every branch whose row
+// minimum exceeds maxEdits, as a Levenshtein automaton would.
+func (d *TermDictionary) Fuzzy(term string, maxEdits int, limit int) []FuzzyMatch {
+	target := []rune(term)
+	row := make([]int, len(target)+1)
+	for i := range row {
+		row[i] = i
+	}
+	matches := []FuzzyMatch{}
+	for i, child := range d.root.children {
+		matches = child.fuzzy(d.root.labels[i], target, row, maxEdits, matches)
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Distance < matches[j].Distance
+	})
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+func (n *trieNode) fuzzy(label rune, target []rune, previous []int, maxEdits int, matches []FuzzyMatch) []FuzzyMatch {
+	row := make([]int, len(previous))
+	row[0] = previous[0] + 1
+	rowMin := row[0]
+	for i := 1; i < len(row); i++ {
+		cost := 1
+		if target[i-1] == label {
+			cost = 0
+		}
+		row[i] = min(row[i-1]+1, previous[i]+1, previous[i-1]+cost)
+		rowMin = min(rowMin, row[i])
+	}
+	if n.isTerm && row[len(row)-1] <= maxEdits {
+		matches = append(matches, FuzzyMatch{Term: n.term, Distance: row[len(row)-1]})
+	}
+	if rowMin <= maxEdits {
+		for i, child := range n.children {
+			matches = child.fuzzy(n.labels[i], target, row, maxEdits, matches)
+		}
+	}
+	return matches
+}
+
+func (bmx *BMX) Term_dictionary_fill() {
+	terms := make([]string, 0, len(bmx.IDF_table))
+	for token := range bmx.IDF_table {
+		terms = append(terms, token)
+	}
+	bmx.Terms = NewTermDictionary(terms)
+}
+
+// ExpansionOptions configures the expansion of query tokens to vocabulary
+// terms. An expanded term gets the token's weight times Decay per edit for
+// fuzzy matches, or times Decay for prefix and wildcard matches.
+type ExpansionOptions struct {
+	Fuzziness      int     // Max edit distance of fuzzy matches, at most 2
+	Prefix         bool    // Expand every token to the terms it prefixes
+	MaxExpansions  int     // Max terms per token, defaults to 50
+	Decay          float64 // Defaults to 0.5
+	MinFuzzyLength int     // Shorter tokens are not fuzzy-expanded, defaults to 3
+}
+
+func (o ExpansionOptions) withDefaults() ExpansionOptions {
+	if o.MaxExpansions <= 0 {
+		o.MaxExpansions = 50
+	}
+	if o.Decay <= 0 {
+		o.Decay = 0.5
+	}
+	if o.MinFuzzyLength <= 0 {
+		o.MinFuzzyLength = 3
+	}
+	o.Fuzziness = min(o.Fuzziness, 2)
+	return o
+}
+
+// expandFuzzy returns the terms within fuzziness edits of token with their
+// weight factors.
+func (bmx *BMX) expandFuzzy(token string, fuzziness int, opts ExpansionOptions) map[string]float64 {
+	opts = opts.withDefaults()
+	fuzziness = min(fuzziness, 2)
+	expanded := map[string]float64{token: 1.0}
+	if fuzziness <= 0 || len([]rune(token)) < opts.MinFuzzyLength || bmx.Terms == nil {
+		return expanded
+	}
+	for _, match := range bmx.Terms.Fuzzy(token, fuzziness, opts.MaxExpansions) {
+		factor := 1.0
+		for i := 0; i < match.Distance; i++ {
+			factor *= opts.Decay
+		}
+		expanded[match.Term] = max(expanded[match.Term], factor)
+	}
+	return expanded
+}
+
+// normalizePattern applies the char filters of the index's analyzer to the
+// literal parts of a wildcard pattern, so that they match the analysed
+// vocabulary (lowercased, diacritics folded).
+func (bmx *BMX) normalizePattern(pattern string) string {
+	analyzer := bmx.TextPreprocessor.Analyzer()
+	var normalized strings.Builder
+	for pattern != "" {
+		i := strings.IndexAny(pattern, "*?")
+		if i < 0 {
+			i = len(pattern)
+		}
+		normalized.WriteString(strings.TrimSpace(analyzer.Normalize(pattern[:i])))
+		if i < len(pattern) {
+			normalized.WriteByte(pattern[i])
+			i++
+		}
+		pattern = pattern[i:]
+	}
+	return normalized.String()
+}
+
+// expandPattern returns the terms matching a prefix (pattern ending with a
+// single *) or wildcard pattern with their weight factors.
+func (bmx *BMX) expandPattern(pattern string, opts ExpansionOptions) map[string]float64 {
+	opts = opts.withDefaults()
+	expanded := map[string]float64{}
+	if bmx.Terms == nil {
+		return expanded
+	}
+	var terms []string
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok && !strings.ContainsAny(prefix, "*?") {
+		terms = bmx.Terms.Prefix(prefix, opts.MaxExpansions)
+	} else {
+		terms = bmx.Terms.Wildcard(pattern, opts.MaxExpansions)
+	}
+	literal := strings.TrimRight(pattern, "*")
+	for _, term := range terms {
+		if term == literal {
+			expanded[term] = 1.0
+		} else {
+			expanded[term] = opts.Decay
+		}
+	}
+	return expanded
+}
+
+// expandToken applies the query's expansion options to a plain query token.
+func (query *Query) expandToken(bmx *BMX, token string) map[string]float64 {
+	expanded := bmx.expandFuzzy(token, query.Expansion.Fuzziness, query.Expansion)
+	if query.Expansion.Prefix {
+		for term, factor := range bmx.expandPattern(token+"*", query.Expansion) {
+			expanded[term] = max(expanded[term], factor)
+		}
+	}
+	return expanded
+}
@@ -0,0 +1,92 @@
+package model
+
+import (
+	"slices"
+	"testing"
+)
+
+var testTerms = []string{"at", "bat", "caat", "cat", "catalog", "cats", "cut", "scat"}
+
+func TestTermDictionaryFuzzy(t *testing.T) {
+	d := NewTermDictionary(testTerms)
+	tests := []struct {
+		term     string
+		maxEdits int
+		want     []FuzzyMatch
+	}{
+		{"cat", 0, []FuzzyMatch{{"cat", 0}}},
+		{"cat", 1, []FuzzyMatch{{"cat", 0}, {"at", 1}, {"bat", 1}, {"caat", 1}, {"cats", 1}, {"cut", 1}, {"scat", 1}}},
+		{"kat", 1, []FuzzyMatch{{"at", 1}, {"bat", 1}, {"cat", 1}}},
+		{"catlog", 1, []FuzzyMatch{{"catalog", 1}}},
+		{"dog", 2, []FuzzyMatch{}},
+		{"", 2, []FuzzyMatch{{"at", 2}}},
+	}
+	for _, tt := range tests {
+		got := d.Fuzzy(tt.term, tt.maxEdits, 0)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Fuzzy(%q, %d) = %v, want %v", tt.term, tt.maxEdits, got, tt.want)
+		}
+	}
+	if got := d.Fuzzy("cat", 1, 2); !slices.Equal(got, []FuzzyMatch{{"cat", 0}, {"at", 1}}) {
+		t.Errorf("Fuzzy(cat, 1) limited to 2 = %v", got)
+	}
+}
+
+func TestTermDictionaryWildcard(t *testing.T) {
+	d := NewTermDictionary(testTerms)
+	tests := []struct {
+		pattern string
+		limit   int
+		want    []string
+	}{
+		{"cat", 0, []string{"cat"}},
+		{"dog", 0, nil},
+		{"cat*", 0, []string{"cat", "catalog", "cats"}},
+		{"*at", 0, []string{"at", "bat", "caat", "cat", "scat"}},
+		{"*at*", 0, []string{"at", "bat", "caat", "cat", "catalog", "cats", "scat"}},
+		{"*at*", 2, []string{"at", "bat"}},
+		{"c?t", 0, []string{"cat", "cut"}},
+		{"c*t", 0, []string{"caat", "cat", "cut"}},
+		{"*a*a*", 0, []string{"caat", "catalog"}},
+		{"**t", 0, []string{"at", "bat", "caat", "cat", "cut", "scat"}},
+	}
+	for _, tt := range tests {
+		if got := d.Wildcard(tt.pattern, tt.limit); !slices.Equal(got, tt.want) {
+			t.Errorf("Wildcard(%q, %d) = %v, want %v", tt.pattern, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestTermDictionaryPrefix(t *testing.T) {
+	d := NewTermDictionary(testTerms)
+	if got := d.Prefix("ca", 0); !slices.Equal(got, []string{"caat", "cat", "catalog", "cats"}) {
+		t.Errorf("Prefix(ca) = %v", got)
+	}
+	if got := d.Prefix("ca", 2); !slices.Equal(got, []string{"caat", "cat"}) {
+		t.Errorf("Prefix(ca) limited to 2 = %v", got)
+	}
+	if got := d.Prefix("x", 0); got != nil {
+		t.Errorf("Prefix(x) = %v, want nil", got)
+	}
+}
+
+func TestNormalizePattern(t *testing.T) {
+	adapter := newTestAdapter(t, "Le café est bon", "Les cafés sont fermés", "un chat noir")
+	tests := []struct{ pattern, want string }{
+		{"CAFÉ*", "cafe*"},
+		{"fermé?", "ferme?"},
+		{"*É*", "*e*"},
+	}
+	for _, tt := range tests {
+		if got := adapter.bmx.normalizePattern(tt.pattern); got != tt.want {
+			t.Errorf("normalizePattern(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+	results, err := adapter.SearchQuery("CAFÉ*", SearchOptions{TopK: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results.Keys) != 2 {
+		t.Errorf("SearchQuery(CAFÉ*) = %v, want both café documents", results.Keys)
+	}
+}
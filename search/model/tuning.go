@@ -0,0 +1,157 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// LabelledQuery is a query with graded relevance judgements (doc id -> grade).
+type LabelledQuery struct {
+	Text      string
+	Relevance map[string]float64
+}
+
+// MetricFunc scores a ranked list of doc ids against relevance judgements.
+type MetricFunc func(ranked []string, relevance map[string]float64) float64
+
+// TuningOptions configures the grid search of TuneParams.
+type TuningOptions struct {
+	Alphas []float64  // Defaults to 0.5, 0.75, 1.0, 1.25, 1.5
+	Betas  []float64  // Defaults to 0, 0.5, 1, 1.5 and 2 times the auto-derived Beta
+	TopK   int        // Defaults to 10
+	Metric MetricFunc // Defaults to NDCGAt(TopK)
+}
+
+// TuningPoint is the mean metric of one (Alpha, Beta) setting.
+type TuningPoint struct {
+	Alpha float64
+	Beta  float64
+	Score float64
+}
+
+// TuningResult reports the best setting, the auto-derived setting for
+// comparison, and every evaluated point sorted by decreasing score.
+type TuningResult struct {
+	Best    TuningPoint
+	Default TuningPoint
+	Grid    []TuningPoint
+}
+
+// TuneParams grid-searches Alpha and Beta against labelled queries. It does
+// not change the index; apply the result with SetParamOverrides. Indexes
+// scored by BM25, which ignores Alpha and Beta, are rejected.
+func (adapter *BMXAdapter) TuneParams(queries []LabelledQuery, opts TuningOptions) (TuningResult, error) {
+	switch scorer := adapter.bmx.Scorer.(type) {
+	case nil, BMXScorer, BMXFScorer:
+	default:
+		return TuningResult{}, fmt.Errorf("scorer %s does not use Alpha and Beta", scorer.Name())
+	}
+	auto := adapter.bmx.autoParams
+	if opts.TopK <= 0 {
+		opts.TopK = 10
+	}
+	if opts.Metric == nil {
+		opts.Metric = NDCGAt(opts.TopK)
+	}
+	if len(opts.Alphas) == 0 {
+		opts.Alphas = []float64{0.5, 0.75, 1.0, 1.25, 1.5}
+	}
+	if len(opts.Betas) == 0 {
+		for _, factor := range []float64{0, 0.5, 1, 1.5, 2} {
+			opts.Betas = append(opts.Betas, factor*auto.Beta)
+		}
+	}
+
+	evaluate := func(alpha, beta float64) TuningPoint {
+		point := TuningPoint{Alpha: alpha, Beta: beta}
+		if len(queries) == 0 {
+			return point
+		}
+		for _, query := range queries {
+			results := adapter.SearchWithOptions(query.Text, SearchOptions{TopK: opts.TopK, Params: FixedParams(alpha, beta)})
+			point.Score += opts.Metric(results.Keys, query.Relevance)
+		}
+		point.Score /= float64(len(queries))
+		return point
+	}
+
+	result := TuningResult{Default: evaluate(auto.Alpha, auto.Beta)}
+	for _, alpha := range opts.Alphas {
+		for _, beta := range opts.Betas {
+			result.Grid = append(result.Grid, evaluate(alpha, beta))
+		}
+	}
+	sort.SliceStable(result.Grid, func(i, j int) bool {
+		return result.Grid[i].Score > result.Grid[j].Score
+	})
+	result.Best = result.Default
+	if len(result.Grid) > 0 && result.Grid[0].Score > result.Default.Score {
+		result.Best = result.Grid[0]
+	}
+	return result, nil
+}
+
+// NDCG is the normalized discounted cumulative gain of the ranked list,
+// against the ideal ranking of every judged document.
+func NDCG(ranked []string, relevance map[string]float64) float64 {
+	return ndcg(ranked, relevance, len(relevance))
+}
+
+// NDCGAt returns NDCG@k: the ranked list and the ideal ranking are both cut
+// off at k, so a list shorter than k is not compared with a short ideal one.
+func NDCGAt(k int) MetricFunc {
+	return func(ranked []string, relevance map[string]float64) float64 {
+		return ndcg(ranked[:min(k, len(ranked))], relevance, k)
+	}
+}
+
+func ndcg(ranked []string, relevance map[string]float64, k int) float64 {
+	dcg := 0.0
+	for i, key := range ranked {
+		dcg += (math.Pow(2, relevance[key]) - 1) / math.Log2(float64(i)+2)
+	}
+	grades := make([]float64, 0, len(relevance))
+	for _, grade := range relevance {
+		grades = append(grades, grade)
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(grades)))
+	idcg := 0.0
+	for i := 0; i < len(grades) && i < k; i++ {
+		idcg += (math.Pow(2, grades[i]) - 1) / math.Log2(float64(i)+2)
+	}
+	if idcg == 0 {
+		return 0
+	}
+	return dcg / idcg
+}
+
+// ReciprocalRank is 1/rank of the first relevant document, 0 if none is retrieved.
+func ReciprocalRank(ranked []string, relevance map[string]float64) float64 {
+	for i, key := range ranked {
+		if relevance[key] > 0 {
+			return 1 / float64(i+1)
+		}
+	}
+	return 0
+}
+
+// Recall is the fraction of relevant documents present in the ranked list.
+func Recall(ranked []string, relevance map[string]float64) float64 {
+	relevant := 0
+	for _, grade := range relevance {
+		if grade > 0 {
+			relevant++
+		}
+	}
+	if relevant == 0 {
+		return 0
+	}
+	found := 0
+	for _, key := range ranked {
+		if relevance[key] > 0 {
+			found++
+		}
+	}
+	return float64(found) / float64(relevant)
+}
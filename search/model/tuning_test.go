@@ -0,0 +1,78 @@
+package model
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	relevance := map[string]float64{"a": 2, "b": 1, "c": 1}
+	gain := func(grade float64, rank int) float64 { return (math.Pow(2, grade) - 1) / math.Log2(float64(rank)+1) }
+	tests := []struct {
+		name   string
+		metric MetricFunc
+		ranked []string
+		want   float64
+	}{
+		{"ndcg ideal", NDCG, []string{"a", "b", "c"}, 1},
+		{"ndcg empty", NDCG, nil, 0},
+		{"ndcg short list", NDCG, []string{"a"}, gain(2, 1) / (gain(2, 1) + gain(1, 2) + gain(1, 3))},
+		{"ndcg@2 short list", NDCGAt(2), []string{"a"}, gain(2, 1) / (gain(2, 1) + gain(1, 2))},
+		{"ndcg@2 cut", NDCGAt(2), []string{"x", "a", "b"}, gain(2, 2) / (gain(2, 1) + gain(1, 2))},
+		{"ndcg@1", NDCGAt(1), []string{"a", "x"}, 1},
+		{"reciprocal rank", ReciprocalRank, []string{"x", "y", "b"}, 1.0 / 3},
+		{"reciprocal rank miss", ReciprocalRank, []string{"x"}, 0},
+		{"recall", Recall, []string{"a", "x", "c"}, 2.0 / 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.metric(tt.ranked, relevance); math.Abs(got-tt.want) > 1e-12 {
+				t.Errorf("metric = %g, want %g", got, tt.want)
+			}
+		})
+	}
+	if got := NDCG([]string{"a"}, nil); got != 0 {
+		t.Errorf("NDCG without judgements = %g, want 0", got)
+	}
+}
+
+func TestTuneParams(t *testing.T) {
+	adapter := newTestAdapter(t, testDocs...)
+	queries := []LabelledQuery{
+		{Text: "lazy dog", Relevance: map[string]float64{"a": 2, "c": 1}},
+		{Text: "quick fox", Relevance: map[string]float64{"b": 2, "a": 1}},
+	}
+	result, err := adapter.TuneParams(queries, TuningOptions{Alphas: []float64{0.5, 1}, Betas: []float64{0, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Grid) != 4 {
+		t.Errorf("%d grid points, want 4", len(result.Grid))
+	}
+	if result.Best.Score < result.Default.Score {
+		t.Errorf("best score %g below the default %g", result.Best.Score, result.Default.Score)
+	}
+	for i := 1; i < len(result.Grid); i++ {
+		if result.Grid[i].Score > result.Grid[i-1].Score {
+			t.Errorf("grid not sorted by decreasing score: %v", result.Grid)
+		}
+	}
+
+	adapter.SetScorer(NewBM25Scorer(1.2, 0.75))
+	if _, err := adapter.TuneParams(queries, TuningOptions{}); err == nil {
+		t.Error("TuneParams with BM25 succeeded, want an error")
+	}
+}
+
+func TestParamOverrides(t *testing.T) {
+	adapter := newTestAdapter(t, testDocs...)
+	auto := adapter.Params()
+	adapter.SetParamOverrides(ParamOverrides{Alpha: new(float64)})
+	if params := adapter.Params(); params.Alpha != 0 || params.Beta != auto.Beta {
+		t.Errorf("Params() = %+v with Alpha pinned to 0, want Beta %g", params, auto.Beta)
+	}
+	adapter.SetParamOverrides(ParamOverrides{})
+	if params := adapter.Params(); params != auto {
+		t.Errorf("Params() = %+v without overrides, want %+v", params, auto)
+	}
+}
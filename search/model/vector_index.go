@@ -0,0 +1,311 @@
+package model
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+// VectorHit is a document and its cosine similarity to the query vector.
+type VectorHit struct {
+	Key   string
+	Score float64
+}
+
+// VectorIndex stores one vector per document and returns the nearest ones
+// by cosine similarity. Adding a key again replaces its vector.
+type VectorIndex interface {
+	Add(key string, vector []float32) error
+	Search(vector []float32, k int, allow func(key string) bool) []VectorHit
+	Len() int
+}
+
+// sortHits orders hits by decreasing score, then key.
+func sortHits(hits []VectorHit) {
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].Key < hits[j].Key
+	})
+}
+
+// BruteForceIndex compares the query with every vector: exact, and fast
+// enough up to a few hundred thousand vectors.
+type BruteForceIndex struct {
+	mu      sync.RWMutex
+	keys    []string
+	vectors [][]float32
+	ids     map[string]int
+}
+
+func NewBruteForceIndex() *BruteForceIndex {
+	return &BruteForceIndex{ids: map[string]int{}}
+}
+
+func (index *BruteForceIndex) Add(key string, vector []float32) error {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	if len(index.vectors) > 0 && len(vector) != len(index.vectors[0]) {
+		return fmt.Errorf("vector of %s has %d dimensions, expected %d", key, len(vector), len(index.vectors[0]))
+	}
+	if id, ok := index.ids[key]; ok {
+		index.vectors[id] = normalizeVector(vector)
+		return nil
+	}
+	index.ids[key] = len(index.keys)
+	index.keys = append(index.keys, key)
+	index.vectors = append(index.vectors, normalizeVector(vector))
+	return nil
+}
+
+func (index *BruteForceIndex) Search(vector []float32, k int, allow func(key string) bool) []VectorHit {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+	query := normalizeVector(vector)
+	hits := []VectorHit{}
+	for id, v := range index.vectors {
+		if len(v) != len(query) || (allow != nil && !allow(index.keys[id])) {
+			continue
+		}
+		hits = append(hits, VectorHit{Key: index.keys[id], Score: dot(query, v)})
+	}
+	sortHits(hits)
+	return hits[:min(k, len(hits))]
+}
+
+func (index *BruteForceIndex) Len() int {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+	return len(index.keys)
+}
+
+// HNSWIndex is a Hierarchical Navigable Small World graph (Malkov &
+// Yashunin, 2016) for approximate nearest neighbour search.
+type HNSWIndex struct {
+	M              int // Neighbours per node and layer, 2*M on layer 0
+	EfConstruction int
+	EfSearch       int
+
+	mu         sync.RWMutex
+	rng        *rand.Rand
+	levelMult  float64
+	nodes      []hnswNode
+	ids        map[string]int
+	entryPoint int
+	maxLevel   int
+}
+
+type hnswNode struct {
+	key       string
+	vector    []float32
+	neighbors [][]int // Per layer
+	deleted   bool    // Replaced by a newer vector of the same key
+}
+
+// NewHNSWIndex returns an index with the usual defaults (M=16,
+// efConstruction=200, efSearch=64) and a fixed seed, so builds are reproducible.
+func NewHNSWIndex() *HNSWIndex {
+	index, _ := NewHNSWIndexWithParams(16, 200, 64, 42)
+	return index
+}
+
+// NewHNSWIndexWithParams returns an index with the given parameters. M must
+// be at least 2, as layers are drawn with probability 1/M per level, and the
+// ef sizes at least 1.
+func NewHNSWIndexWithParams(m, efConstruction, efSearch int, seed int64) (*HNSWIndex, error) {
+	if m < 2 {
+		return nil, fmt.Errorf("hnsw: M is %d, must be at least 2", m)
+	}
+	if efConstruction < 1 || efSearch < 1 {
+		return nil, fmt.Errorf("hnsw: efConstruction and efSearch are %d and %d, must be at least 1", efConstruction, efSearch)
+	}
+	return &HNSWIndex{
+		M:              m,
+		EfConstruction: efConstruction,
+		EfSearch:       efSearch,
+		rng:            rand.New(rand.NewSource(seed)),
+		levelMult:      1 / math.Log(float64(m)),
+		ids:            map[string]int{},
+		entryPoint:     -1,
+	}, nil
+}
+
+func (index *HNSWIndex) Len() int {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+	return len(index.ids)
+}
+
+func (index *HNSWIndex) distance(a []float32, b int) float64 {
+	return 1 - dot(a, index.nodes[b].vector)
+}
+
+// candidate is a node and its distance to the query.
+type candidate struct {
+	id       int
+	distance float64
+}
+
+// candidateHeap is a min-heap on distance, or a max-heap when far is set.
+type candidateHeap struct {
+	items []candidate
+	far   bool
+}
+
+func (h *candidateHeap) Len() int { return len(h.items) }
+func (h *candidateHeap) Less(i, j int) bool {
+	if h.far {
+		return h.items[i].distance > h.items[j].distance
+	}
+	return h.items[i].distance < h.items[j].distance
+}
+func (h *candidateHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *candidateHeap) Push(x any)    { h.items = append(h.items, x.(candidate)) }
+func (h *candidateHeap) Pop() any {
+	x := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return x
+}
+
+// searchLayer returns the ef nearest nodes found from the entry points on a
+// layer, nearest first.
+func (index *HNSWIndex) searchLayer(query []float32, entryPoints []int, ef int, layer int) []candidate {
+	visited := map[int]bool{}
+	candidates := &candidateHeap{}
+	nearest := &candidateHeap{far: true}
+	for _, ep := range entryPoints {
+		visited[ep] = true
+		c := candidate{id: ep, distance: index.distance(query, ep)}
+		heap.Push(candidates, c)
+		heap.Push(nearest, c)
+	}
+	for candidates.Len() > 0 {
+		current := heap.Pop(candidates).(candidate)
+		if nearest.Len() >= ef && current.distance > nearest.items[0].distance {
+			break
+		}
+		for _, neighbor := range index.nodes[current.id].neighbors[layer] {
+			if visited[neighbor] {
+				continue
+			}
+			visited[neighbor] = true
+			d := index.distance(query, neighbor)
+			if nearest.Len() < ef || d < nearest.items[0].distance {
+				heap.Push(candidates, candidate{id: neighbor, distance: d})
+				heap.Push(nearest, candidate{id: neighbor, distance: d})
+				if nearest.Len() > ef {
+					heap.Pop(nearest)
+				}
+			}
+		}
+	}
+	result := make([]candidate, nearest.Len())
+	for i := len(result) - 1; i >= 0; i-- {
+		result[i] = heap.Pop(nearest).(candidate)
+	}
+	return result
+}
+
+// selectNeighbors keeps the m nearest candidates.
+func selectNeighbors(candidates []candidate, m int) []int {
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].distance < candidates[j].distance })
+	neighbors := make([]int, 0, m)
+	for _, c := range candidates[:min(m, len(candidates))] {
+		neighbors = append(neighbors, c.id)
+	}
+	return neighbors
+}
+
+func (index *HNSWIndex) Add(key string, vector []float32) error {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	if len(index.nodes) > 0 && len(vector) != len(index.nodes[0].vector) {
+		return fmt.Errorf("vector of %s has %d dimensions, expected %d", key, len(vector), len(index.nodes[0].vector))
+	}
+	if old, ok := index.ids[key]; ok {
+		// The old node stays in the graph for navigation but is never returned.
+		index.nodes[old].deleted = true
+	}
+
+	id := len(index.nodes)
+	level := int(-math.Log(1-index.rng.Float64()) * index.levelMult)
+	node := hnswNode{key: key, vector: normalizeVector(vector), neighbors: make([][]int, level+1)}
+	index.nodes = append(index.nodes, node)
+	index.ids[key] = id
+
+	if index.entryPoint < 0 {
+		index.entryPoint, index.maxLevel = id, level
+		return nil
+	}
+
+	entry := index.entryPoint
+	for layer := index.maxLevel; layer > level; layer-- {
+		entry = index.searchLayer(node.vector, []int{entry}, 1, layer)[0].id
+	}
+	entryPoints := []int{entry}
+	for layer := min(level, index.maxLevel); layer >= 0; layer-- {
+		candidates := index.searchLayer(node.vector, entryPoints, index.EfConstruction, layer)
+		maxNeighbors := index.M
+		if layer == 0 {
+			maxNeighbors = 2 * index.M
+		}
+		neighbors := selectNeighbors(append([]candidate(nil), candidates...), index.M)
+		index.nodes[id].neighbors[layer] = neighbors
+		for _, neighbor := range neighbors {
+			links := append(index.nodes[neighbor].neighbors[layer], id)
+			if len(links) > maxNeighbors {
+				pruned := make([]candidate, len(links))
+				for i, link := range links {
+					pruned[i] = candidate{id: link, distance: index.distance(index.nodes[neighbor].vector, link)}
+				}
+				links = selectNeighbors(pruned, maxNeighbors)
+			}
+			index.nodes[neighbor].neighbors[layer] = links
+		}
+		entryPoints = entryPoints[:0]
+		for _, c := range candidates {
+			entryPoints = append(entryPoints, c.id)
+		}
+	}
+	if level > index.maxLevel {
+		index.entryPoint, index.maxLevel = id, level
+	}
+	return nil
+}
+
+func (index *HNSWIndex) Search(vector []float32, k int, allow func(key string) bool) []VectorHit {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+	if index.entryPoint < 0 || len(vector) != len(index.nodes[0].vector) {
+		return nil
+	}
+	query := normalizeVector(vector)
+	entry := index.entryPoint
+	for layer := index.maxLevel; layer > 0; layer-- {
+		entry = index.searchLayer(query, []int{entry}, 1, layer)[0].id
+	}
+	// Filtered and replaced nodes are skipped after the search, so widen it.
+	ef := max(index.EfSearch, 2*k)
+	hits := []VectorHit{}
+	for len(hits) < k {
+		hits = hits[:0]
+		candidates := index.searchLayer(query, []int{entry}, ef, 0)
+		for _, c := range candidates {
+			node := index.nodes[c.id]
+			if node.deleted || (allow != nil && !allow(node.key)) {
+				continue
+			}
+			hits = append(hits, VectorHit{Key: node.key, Score: 1 - c.distance})
+		}
+		if len(candidates) < ef || ef >= len(index.nodes) {
+			break
+		}
+		ef *= 2
+	}
+	sortHits(hits)
+	return hits[:min(k, len(hits))]
+}
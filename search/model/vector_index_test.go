@@ -0,0 +1,109 @@
+package model
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func randomVectors(n, dimensions int, seed int64) [][]float32 {
+	rng := rand.New(rand.NewSource(seed))
+	vectors := make([][]float32, n)
+	for i := range vectors {
+		vectors[i] = make([]float32, dimensions)
+		for j := range vectors[i] {
+			vectors[i][j] = float32(rng.NormFloat64())
+		}
+	}
+	return vectors
+}
+
+func TestVectorIndexes(t *testing.T) {
+	indexes := []struct {
+		name  string
+		index VectorIndex
+	}{
+		{"brute force", NewBruteForceIndex()},
+		{"hnsw", NewHNSWIndex()},
+	}
+	for _, tt := range indexes {
+		t.Run(tt.name, func(t *testing.T) {
+			if hits := tt.index.Search([]float32{1, 0}, 3, nil); len(hits) != 0 {
+				t.Errorf("Search on an empty index = %v", hits)
+			}
+			vectors := map[string][]float32{"x": {1, 0}, "y": {0, 1}, "xy": {1, 1}, "-x": {-1, 0}}
+			for _, key := range []string{"x", "y", "xy", "-x"} {
+				if err := tt.index.Add(key, vectors[key]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := tt.index.Add("z", []float32{1, 0, 0}); err == nil {
+				t.Error("Add with other dimensions succeeded, want an error")
+			}
+
+			hits := tt.index.Search([]float32{2, 0.1}, 2, nil)
+			if len(hits) != 2 || hits[0].Key != "x" || hits[1].Key != "xy" {
+				t.Errorf("Search = %v, want x then xy", hits)
+			}
+			hits = tt.index.Search([]float32{2, 0.1}, 4, func(key string) bool { return key != "x" })
+			if len(hits) != 3 || hits[0].Key != "xy" {
+				t.Errorf("Search without x = %v, want xy first of 3", hits)
+			}
+
+			// Replacing a vector moves the key.
+			if err := tt.index.Add("x", []float32{0, -1}); err != nil {
+				t.Fatal(err)
+			}
+			if tt.index.Len() != 4 {
+				t.Errorf("Len = %d after replacing a vector, want 4", tt.index.Len())
+			}
+			hits = tt.index.Search([]float32{0, -1}, 1, nil)
+			if len(hits) != 1 || hits[0].Key != "x" {
+				t.Errorf("Search for the replaced vector = %v, want x", hits)
+			}
+		})
+	}
+}
+
+func TestNewHNSWIndexWithParams(t *testing.T) {
+	for _, params := range [][3]int{{1, 200, 64}, {0, 200, 64}, {16, 0, 64}, {16, 200, 0}} {
+		if _, err := NewHNSWIndexWithParams(params[0], params[1], params[2], 1); err == nil {
+			t.Errorf("NewHNSWIndexWithParams(%v) succeeded, want an error", params)
+		}
+	}
+	if _, err := NewHNSWIndexWithParams(2, 1, 1, 1); err != nil {
+		t.Errorf("NewHNSWIndexWithParams(2, 1, 1): %v", err)
+	}
+}
+
+// TestHNSWRecall compares HNSW with exact search on random vectors.
+func TestHNSWRecall(t *testing.T) {
+	const n, dimensions, k = 2000, 16, 10
+	exact := NewBruteForceIndex()
+	hnsw := NewHNSWIndex()
+	for i, vector := range randomVectors(n, dimensions, 1) {
+		key := fmt.Sprintf("v%d", i)
+		if err := exact.Add(key, vector); err != nil {
+			t.Fatal(err)
+		}
+		if err := hnsw.Add(key, vector); err != nil {
+			t.Fatal(err)
+		}
+	}
+	found, total := 0, 0
+	for _, query := range randomVectors(50, dimensions, 2) {
+		want := map[string]bool{}
+		for _, hit := range exact.Search(query, k, nil) {
+			want[hit.Key] = true
+		}
+		for _, hit := range hnsw.Search(query, k, nil) {
+			if want[hit.Key] {
+				found++
+			}
+		}
+		total += k
+	}
+	if recall := float64(found) / float64(total); recall < 0.9 {
+		t.Errorf("HNSW recall@%d = %.2f, want at least 0.9", k, recall)
+	}
+}
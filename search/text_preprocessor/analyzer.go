@@ -0,0 +1,344 @@
+package text_preprocessor
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// CharFilter transforms text before tokenization.
+type CharFilter func(string) string
+
+// TokenFilter transforms the tokens produced by the tokenizer.
+type TokenFilter func([]string) []string
+
+// CharFilterFactory and TokenFilterFactory build a filter from the argument
+// of its spec: "stopwords:french" passes "french" to the stopwords factory.
+type CharFilterFactory func(arg string) (CharFilter, error)
+type TokenFilterFactory func(arg string) (TokenFilter, error)
+
+// registryMu guards the registries of tokenizers, filters and analyzers,
+// which can be written while indexes are searched.
+var registryMu sync.RWMutex
+
+// charFiltersDict maps char filter names to their factories.
+var charFiltersDict = map[string]CharFilterFactory{
+	"lowercase":     fixedCharFilter(Lowercasing),
+	"ampersand":     fixedCharFilter(NormalizeAmpersand),
+	"special_chars": fixedCharFilter(NormalizeSpecialChars),
+	"acronyms":      fixedCharFilter(NormalizeAcronyms),
+	"punctuation":   fixedCharFilter(RemovePunctuation),
+	"diacritics":    diacriticsCharFilter, // Argument: comma-separated scripts to keep, e.g. "diacritics:Han,Thai"
+	"whitespace":    fixedCharFilter(StripWhitespaces),
+}
+
+// normalizingTokenFilters are the token filters mapping characters one to
+// one, which Normalize applies.
+var normalizingTokenFilters = map[string]bool{"lowercase": true, "diacritics": true}
+
+// tokenFiltersDict maps token filter names to their factories.
+var tokenFiltersDict = map[string]TokenFilterFactory{
+	"lowercase":  fixedTokenFilter(mapTokens(Lowercasing)),
+	"diacritics": diacriticsTokenFilter,
+	"stopwords":  stopwordsTokenFilter, // Argument: language of the stopwords, e.g. "stopwords:french"
+	"stemmer":    stemmerTokenFilter,   // Argument: name of the stemmer, e.g. "stemmer:french"
+}
+
+func fixedCharFilter(filter CharFilter) CharFilterFactory {
+	return func(arg string) (CharFilter, error) {
+		if arg != "" {
+			return nil, fmt.Errorf("char filter takes no argument, got %q", arg)
+		}
+		return filter, nil
+	}
+}
+
+func fixedTokenFilter(filter TokenFilter) TokenFilterFactory {
+	return func(arg string) (TokenFilter, error) {
+		if arg != "" {
+			return nil, fmt.Errorf("token filter takes no argument, got %q", arg)
+		}
+		return filter, nil
+	}
+}
+
+// mapTokens returns a token filter applying f to each token.
+func mapTokens(f func(string) string) TokenFilter {
+	return func(tokens []string) []string {
+		mapped := make([]string, len(tokens))
+		for i, token := range tokens {
+			mapped[i] = f(token)
+		}
+		return mapped
+	}
+}
+
+// preservedScripts returns the unicode.Scripts tables named in names,
+// unknown names skipped.
+func preservedScripts(names []string) []*unicode.RangeTable {
+	var scripts []*unicode.RangeTable
+	for _, name := range names {
+		if script, ok := unicode.Scripts[strings.TrimSpace(name)]; ok {
+			scripts = append(scripts, script)
+		}
+	}
+	return scripts
+}
+
+func diacriticsFilter(arg string) (func(string) string, error) {
+	if arg == "" {
+		return NormalizeDiacritics, nil
+	}
+	names := strings.Split(arg, ",")
+	scripts := preservedScripts(names)
+	if len(scripts) != len(names) {
+		return nil, fmt.Errorf("unknown script in %q", arg)
+	}
+	return NormalizeDiacriticsExcept(scripts...), nil
+}
+
+func diacriticsCharFilter(arg string) (CharFilter, error) {
+	return diacriticsFilter(arg)
+}
+
+func diacriticsTokenFilter(arg string) (TokenFilter, error) {
+	filter, err := diacriticsFilter(arg)
+	if err != nil {
+		return nil, err
+	}
+	return mapTokens(func(token string) string { return strings.TrimSpace(filter(token)) }), nil
+}
+
+func stopwordsTokenFilter(lang string) (TokenFilter, error) {
+	words, err := getStopwords(lang)
+	if err != nil {
+		return nil, err
+	}
+	return stopwordsFilter(stopwordSet(words)), nil
+}
+
+func stopwordsFilter(stopwords map[string]struct{}) TokenFilter {
+	return wordFilter(func(words []string) []string {
+		return RemoveStopwords(words, stopwords)
+	})
+}
+
+func stemmerTokenFilter(name string) (TokenFilter, error) {
+	stemmer, err := GetStemmer(name)
+	if err != nil {
+		return nil, err
+	}
+	return stemmerFilter(stemmer), nil
+}
+
+func stemmerFilter(stemmer StemmerFunc) TokenFilter {
+	return wordFilter(func(words []string) []string {
+		return ApplyStemmer(words, stemmer)
+	})
+}
+
+// wordFilter applies filter to the words of tokens holding several words,
+// such as those of the sent tokenizer, and rejoins them.
+func wordFilter(filter TokenFilter) TokenFilter {
+	return func(tokens []string) []string {
+		multiword := false
+		for _, token := range tokens {
+			if strings.IndexFunc(token, unicode.IsSpace) >= 0 {
+				multiword = true
+				break
+			}
+		}
+		if !multiword {
+			return filter(tokens)
+		}
+		filtered := make([]string, 0, len(tokens))
+		for _, token := range tokens {
+			filtered = append(filtered, strings.Join(filter(strings.Fields(token)), " "))
+		}
+		return filtered
+	}
+}
+
+// RegisterCharFilter registers a char filter under name, replacing any
+// filter of that name.
+func RegisterCharFilter(name string, filter CharFilter) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	charFiltersDict[strings.ToLower(name)] = fixedCharFilter(filter)
+}
+
+// RegisterCharFilterFactory registers a char filter taking an argument.
+func RegisterCharFilterFactory(name string, factory CharFilterFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	charFiltersDict[strings.ToLower(name)] = factory
+}
+
+// RegisterTokenFilter registers a token filter under name, replacing any
+// filter of that name.
+func RegisterTokenFilter(name string, filter TokenFilter) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	tokenFiltersDict[strings.ToLower(name)] = fixedTokenFilter(filter)
+}
+
+// RegisterTokenFilterFactory registers a token filter taking an argument.
+func RegisterTokenFilterFactory(name string, factory TokenFilterFactory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	tokenFiltersDict[strings.ToLower(name)] = factory
+}
+
+// AnalyzerDefinition describes an analysis chain by the names of its
+// components, so that it can be stored with an index. Filters run in the
+// order given; a filter spec is a registered name optionally followed by
+// ":" and an argument.
+type AnalyzerDefinition struct {
+	Name         string   `json:"name"`
+	CharFilters  []string `json:"char_filters,omitempty"`
+	Tokenizer    string   `json:"tokenizer"`
+	TokenFilters []string `json:"token_filters,omitempty"`
+}
+
+// Analyzer turns text into tokens: char filters, then the tokenizer, then
+// token filters. Empty tokens are always dropped.
+type Analyzer struct {
+	definition   *AnalyzerDefinition // nil when built from functions rather than names
+	charFilters  []CharFilter
+	tokenizer    TokenizerFunc
+	tokenFilters []TokenFilter
+	normalizers  []TokenFilter // Token filters applied by Normalize
+}
+
+// NewAnalyzer builds the analyzer of a definition, resolving its components
+// in the registries.
+func NewAnalyzer(def AnalyzerDefinition) (*Analyzer, error) {
+	tokenizer, err := getTokenizer(def.Tokenizer)
+	if err != nil {
+		return nil, fmt.Errorf("analyzer %s: %w", def.Name, err)
+	}
+	a := &Analyzer{tokenizer: tokenizer}
+	for _, spec := range def.CharFilters {
+		name, arg, _ := strings.Cut(spec, ":")
+		registryMu.RLock()
+		factory, ok := charFiltersDict[strings.ToLower(name)]
+		registryMu.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("analyzer %s: char filter %s not supported", def.Name, name)
+		}
+		filter, err := factory(arg)
+		if err != nil {
+			return nil, fmt.Errorf("analyzer %s: char filter %s: %w", def.Name, name, err)
+		}
+		a.charFilters = append(a.charFilters, filter)
+	}
+	for _, spec := range def.TokenFilters {
+		name, arg, _ := strings.Cut(spec, ":")
+		registryMu.RLock()
+		factory, ok := tokenFiltersDict[strings.ToLower(name)]
+		registryMu.RUnlock()
+		if !ok {
+			return nil, fmt.Errorf("analyzer %s: token filter %s not supported", def.Name, name)
+		}
+		filter, err := factory(arg)
+		if err != nil {
+			return nil, fmt.Errorf("analyzer %s: token filter %s: %w", def.Name, name, err)
+		}
+		a.tokenFilters = append(a.tokenFilters, filter)
+		if normalizingTokenFilters[strings.ToLower(name)] {
+			a.normalizers = append(a.normalizers, filter)
+		}
+	}
+	def.CharFilters = append([]string(nil), def.CharFilters...)
+	def.TokenFilters = append([]string(nil), def.TokenFilters...)
+	a.definition = &def
+	return a, nil
+}
+
+// Definition returns the definition of the analyzer, false when it was
+// built from functions (a Config without Analyzer) and cannot be stored.
+func (a *Analyzer) Definition() (AnalyzerDefinition, bool) {
+	if a.definition == nil {
+		return AnalyzerDefinition{}, false
+	}
+	def := *a.definition
+	def.CharFilters = append([]string(nil), def.CharFilters...)
+	def.TokenFilters = append([]string(nil), def.TokenFilters...)
+	return def, true
+}
+
+// Analyze returns the tokens of text.
+func (a *Analyzer) Analyze(text string) []string {
+	for _, filter := range a.charFilters {
+		text = filter(text)
+	}
+	tokens := RemoveEmptyTokens(a.tokenizer(text))
+	for _, filter := range a.tokenFilters {
+		tokens = RemoveEmptyTokens(filter(tokens))
+	}
+	return tokens
+}
+
+// Normalize returns text after the char filters and the token filters
+// mapping characters (lowercase, diacritics), without tokenizing it, stemming
+// it or removing stopwords. Wildcard patterns are normalized this way.
+func (a *Analyzer) Normalize(text string) string {
+	for _, filter := range a.charFilters {
+		text = filter(text)
+	}
+	for _, filter := range a.normalizers {
+		if tokens := filter([]string{text}); len(tokens) == 1 {
+			text = tokens[0]
+		}
+	}
+	return text
+}
+
+// analyzersDict maps analyzer names to their definitions.
+var analyzersDict = map[string]AnalyzerDefinition{
+	"standard": {
+		Name:         "standard",
+		CharFilters:  []string{"lowercase", "ampersand", "special_chars", "diacritics", "whitespace"},
+		Tokenizer:    "uax29",
+		TokenFilters: []string{"stopwords:english", "stemmer:english"},
+	},
+	"simple": {
+		Name:         "simple",
+		CharFilters:  []string{"lowercase"},
+		Tokenizer:    "uax29",
+		TokenFilters: []string{"diacritics"},
+	},
+}
+
+// RegisterAnalyzer validates def and registers it under its name, replacing
+// any analyzer of that name.
+func RegisterAnalyzer(def AnalyzerDefinition) error {
+	if def.Name == "" {
+		return fmt.Errorf("analyzer has no name")
+	}
+	if _, err := NewAnalyzer(def); err != nil {
+		return err
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	analyzersDict[strings.ToLower(def.Name)] = def
+	return nil
+}
+
+// GetAnalyzerDefinition returns the definition of a registered analyzer.
+func GetAnalyzerDefinition(name string) (AnalyzerDefinition, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	def, ok := analyzersDict[strings.ToLower(name)]
+	return def, ok
+}
+
+// GetAnalyzer builds a registered analyzer.
+func GetAnalyzer(name string) (*Analyzer, error) {
+	def, ok := GetAnalyzerDefinition(name)
+	if !ok {
+		return nil, fmt.Errorf("analyzer %s not registered", name)
+	}
+	return NewAnalyzer(def)
+}
@@ -0,0 +1,118 @@
+package text_preprocessor
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestAnalyzer(t *testing.T) {
+	tests := []struct {
+		name string
+		def  AnalyzerDefinition
+		text string
+		want []string
+	}{
+		{
+			name: "standard",
+			def:  analyzersDict["standard"],
+			text: "The Cafés & the running dogs",
+			want: []string{"cafe", "run", "dog"},
+		},
+		{
+			name: "standard keeps numbers and emails",
+			def:  analyzersDict["standard"],
+			text: "e-mail foo@bar.com 1,000.50",
+			want: []string{"mail", "foo@bar.com", "1,000.50"},
+		},
+		{
+			name: "simple",
+			def:  analyzersDict["simple"],
+			text: "The Cafés & the running dogs",
+			want: []string{"the", "cafes", "the", "running", "dogs"},
+		},
+		{
+			name: "diacritics keeping scripts",
+			def:  AnalyzerDefinition{CharFilters: []string{"diacritics:Thai"}, Tokenizer: "whitespace"},
+			text: "naïve ภาษา",
+			want: []string{"naive", "ภาษา"},
+		},
+		{
+			name: "stopwords per word of sentences",
+			def:  AnalyzerDefinition{CharFilters: []string{"lowercase"}, Tokenizer: "sent", TokenFilters: []string{"stopwords:english"}},
+			text: "The cat sat. A dog ran.",
+			want: []string{"cat sat.", "dog ran."},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			analyzer, err := NewAnalyzer(tt.def)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := analyzer.Analyze(tt.text); !slices.Equal(got, tt.want) {
+				t.Errorf("Analyze(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewAnalyzerErrors(t *testing.T) {
+	defs := []AnalyzerDefinition{
+		{Tokenizer: "nope"},
+		{Tokenizer: "word", CharFilters: []string{"nope"}},
+		{Tokenizer: "word", CharFilters: []string{"lowercase:arg"}},
+		{Tokenizer: "word", CharFilters: []string{"diacritics:Klingon"}},
+		{Tokenizer: "word", TokenFilters: []string{"stopwords:klingon"}},
+		{Tokenizer: "word", TokenFilters: []string{"stemmer:klingon"}},
+	}
+	for _, def := range defs {
+		if _, err := NewAnalyzer(def); err == nil {
+			t.Errorf("NewAnalyzer(%+v) succeeded, want an error", def)
+		}
+	}
+}
+
+func TestAnalyzerNormalize(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"standard", "CAFÉ", "cafe"},
+		{"simple", "CAFÉ", "cafe"},
+	}
+	for _, tt := range tests {
+		analyzer, err := GetAnalyzer(tt.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := analyzer.Normalize(tt.text); got != tt.want {
+			t.Errorf("%s Normalize(%q) = %q, want %q", tt.name, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestRegisterAnalyzer(t *testing.T) {
+	RegisterCharFilter("test_reverse", func(text string) string {
+		runes := []rune(text)
+		slices.Reverse(runes)
+		return string(runes)
+	})
+	def := AnalyzerDefinition{Name: "test_reversed", CharFilters: []string{"test_reverse"}, Tokenizer: "whitespace"}
+	if err := RegisterAnalyzer(def); err != nil {
+		t.Fatal(err)
+	}
+	analyzer, err := GetAnalyzer("TEST_REVERSED")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := analyzer.Analyze("ab cd"); !slices.Equal(got, []string{"dc", "ba"}) {
+		t.Errorf("Analyze(ab cd) = %q", got)
+	}
+	if got, ok := analyzer.Definition(); !ok || got.Name != def.Name || !slices.Equal(got.CharFilters, def.CharFilters) {
+		t.Errorf("Definition() = %+v, %v", got, ok)
+	}
+	if err := RegisterAnalyzer(AnalyzerDefinition{Tokenizer: "word"}); err == nil {
+		t.Error("RegisterAnalyzer without a name succeeded, want an error")
+	}
+}
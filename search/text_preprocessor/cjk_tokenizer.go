@@ -0,0 +1,142 @@
+package text_preprocessor
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// segmentedScripts are the scripts written without spaces between words,
+// which are split into character bigrams or by dictionary.
+var segmentedScripts = []*unicode.RangeTable{
+	unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul,
+	unicode.Thai, unicode.Lao, unicode.Khmer, unicode.Myanmar, kanaMarks,
+}
+
+// kanaMarks are the prolonged sound mark (ー), the voiced sound marks and
+// the kana iteration marks, which belong to the Common script but are part
+// of Japanese words (Katakana in UAX #29).
+var kanaMarks = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x3031, Hi: 0x3035, Stride: 1},
+		{Lo: 0x309B, Hi: 0x309C, Stride: 1},
+		{Lo: 0x30A0, Hi: 0x30A0, Stride: 1},
+		{Lo: 0x30FC, Hi: 0x30FC, Stride: 1},
+		{Lo: 0xFF70, Hi: 0xFF70, Stride: 1},
+	},
+}
+
+// CJKScripts lists the script names to keep in Config.PreserveScripts when
+// indexing Chinese, Japanese, Korean or Thai text.
+var CJKScripts = []string{"Han", "Hiragana", "Katakana", "Hangul", "Thai", "Lao", "Khmer", "Myanmar"}
+
+func isSegmented(r rune) bool {
+	return unicode.In(r, segmentedScripts...)
+}
+
+// splitRuns returns the words of text outside segmented scripts, and the
+// tokens given by segment for each run of segmented-script characters.
+func splitRuns(text string, segment func([]rune) []string) []string {
+	var tokens []string
+	var word, run []rune
+	flush := func() {
+		if len(word) > 0 {
+			tokens = append(tokens, string(word))
+			word = word[:0]
+		}
+		if len(run) > 0 {
+			tokens = append(tokens, segment(run)...)
+			run = run[:0]
+		}
+	}
+	for _, r := range text {
+		switch {
+		case isSegmented(r) || (len(run) > 0 && unicode.IsMark(r)):
+			if len(word) > 0 {
+				flush()
+			}
+			run = append(run, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r):
+			if len(run) > 0 {
+				flush()
+			}
+			word = append(word, r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return tokens
+}
+
+// bigrams returns the overlapping character bigrams of run, or run itself
+// when it is a single character. Combining marks (Thai vowels and tones)
+// stay with their base character.
+func bigrams(run []rune) []string {
+	var clusters []string
+	for i := 0; i < len(run); {
+		j := i + 1
+		for j < len(run) && unicode.IsMark(run[j]) {
+			j++
+		}
+		clusters = append(clusters, string(run[i:j]))
+		i = j
+	}
+	if len(clusters) == 1 {
+		return clusters
+	}
+	tokens := make([]string, 0, len(clusters)-1)
+	for i := 0; i+1 < len(clusters); i++ {
+		tokens = append(tokens, clusters[i]+clusters[i+1])
+	}
+	return tokens
+}
+
+// cjkTokenizer splits words on Unicode letters and digits, and Chinese,
+// Japanese, Korean and Thai text into overlapping character bigrams.
+func cjkTokenizer(text string) []string {
+	return splitRuns(text, bigrams)
+}
+
+// NewDictionaryTokenizer returns a tokenizer segmenting Chinese, Japanese
+// and Thai text by forward maximum matching against words. Stretches
+// matching no word fall back to character bigrams; other scripts are split
+// like with the "cjk" tokenizer.
+func NewDictionaryTokenizer(words []string) TokenizerFunc {
+	dictionary := make(map[string]struct{}, len(words))
+	maxLen := 1
+	for _, word := range words {
+		dictionary[word] = struct{}{}
+		maxLen = max(maxLen, utf8.RuneCountInString(word))
+	}
+	segment := func(run []rune) []string {
+		var unknown []rune
+		var result []string
+		for i := 0; i < len(run); {
+			matched := 0
+			for n := min(maxLen, len(run)-i); n > 0; n-- {
+				if _, ok := dictionary[string(run[i:i+n])]; ok {
+					matched = n
+					break
+				}
+			}
+			if matched == 0 {
+				unknown = append(unknown, run[i])
+				i++
+				continue
+			}
+			if len(unknown) > 0 {
+				result = append(result, bigrams(unknown)...)
+				unknown = unknown[:0]
+			}
+			result = append(result, string(run[i:i+matched]))
+			i += matched
+		}
+		if len(unknown) > 0 {
+			result = append(result, bigrams(unknown)...)
+		}
+		return result
+	}
+	return func(text string) []string {
+		return splitRuns(text, segment)
+	}
+}
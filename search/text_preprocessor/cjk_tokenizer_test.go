@@ -0,0 +1,55 @@
+package text_preprocessor
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCJKTokenizer(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"東京", []string{"東京"}},
+		{"東京都", []string{"東京", "京都"}},
+		{"我爱 Go 语言", []string{"我爱", "Go", "语言"}},
+		{"日", []string{"日"}},
+		{"ภาษาไทย", []string{"ภา", "าษ", "ษา", "าไ", "ไท", "ทย"}},
+	}
+	for _, tt := range tests {
+		if got := cjkTokenizer(tt.text); !slices.Equal(got, tt.want) {
+			t.Errorf("cjkTokenizer(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestDictionaryTokenizer(t *testing.T) {
+	tokenize := NewDictionaryTokenizer([]string{"東京", "タワー", "行く"})
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"東京タワー", []string{"東京", "タワー"}},
+		{"東京タワーへ行く", []string{"東京", "タワー", "へ", "行く"}},
+		{"大阪城", []string{"大阪", "阪城"}},
+		{"visit 東京!", []string{"visit", "東京"}},
+	}
+	for _, tt := range tests {
+		if got := tokenize(tt.text); !slices.Equal(got, tt.want) {
+			t.Errorf("dictionary tokens of %q = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestDiacriticsKeepKanaMarks(t *testing.T) {
+	analyzer, err := NewAnalyzer(AnalyzerDefinition{
+		CharFilters: []string{"lowercase", "diacritics:Han,Hiragana,Katakana"},
+		Tokenizer:   "cjk",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := analyzer.Analyze("タワー café"); !slices.Equal(got, []string{"タワ", "ワー", "cafe"}) {
+		t.Errorf("Analyze(タワー café) = %q, want the prolonged sound mark kept", got)
+	}
+}
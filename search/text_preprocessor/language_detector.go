@@ -0,0 +1,256 @@
+package text_preprocessor
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// languageScripts maps the languages not written in the Latin script to
+// their script.
+var languageScripts = map[string]*unicode.RangeTable{
+	"arabic":  unicode.Arabic,
+	"bengali": unicode.Bengali,
+	"chinese": unicode.Han,
+	"greek":   unicode.Greek,
+	"hebrew":  unicode.Hebrew,
+	"kazakh":  unicode.Cyrillic,
+	"nepali":  unicode.Devanagari,
+	"russian": unicode.Cyrillic,
+	"tajik":   unicode.Cyrillic,
+}
+
+func languageScript(lang string) *unicode.RangeTable {
+	if script, ok := languageScripts[lang]; ok {
+		return script
+	}
+	return unicode.Latin
+}
+
+// latinAlphabets lists the letters beyond ASCII of the languages written in
+// the Latin script. A word with a letter missing from the alphabet of a
+// language, such as the á of Spanish in Catalan, counts against it.
+var latinAlphabets = map[string]string{
+	"azerbaijani": "çəğıöşü",
+	"basque":      "ñü",
+	"catalan":     "àçèéíïòóúü",
+	"danish":      "æøåé",
+	"dutch":       "éëïóöü",
+	"english":     "",
+	"finnish":     "äöå",
+	"french":      "àâæçéèêëîïôœùûüÿ",
+	"german":      "äöüß",
+	"hinglish":    "",
+	"hungarian":   "áéíóöőúüű",
+	"indonesian":  "",
+	"italian":     "àèéìíîòóùú",
+	"norwegian":   "æøåéèóô",
+	"portuguese":  "áâãàçéêíóôõú",
+	"romanian":    "ăâîșțşţ",
+	"slovene":     "čšž",
+	"spanish":     "áéíñóúü",
+	"swedish":     "åäöé",
+	"turkish":     "çğıöşüâî",
+}
+
+// foreignWords counts the words with a Latin letter missing from the
+// alphabet of lang.
+func foreignWords(words []string, lang string) int {
+	alphabet, ok := latinAlphabets[lang]
+	if !ok {
+		return 0
+	}
+	foreign := 0
+	for _, word := range words {
+		for _, r := range word {
+			if r > unicode.MaxASCII && unicode.Is(unicode.Latin, r) && !strings.ContainsRune(alphabet, r) {
+				foreign++
+				break
+			}
+		}
+	}
+	return foreign
+}
+
+var detectableScripts = []*unicode.RangeTable{
+	unicode.Latin, unicode.Cyrillic, unicode.Greek, unicode.Arabic, unicode.Hebrew,
+	unicode.Han, unicode.Bengali, unicode.Devanagari,
+}
+
+// LanguageDetector identifies the language of a text. Candidates are first
+// narrowed down by the dominant script of the text, then scored by the
+// share of its words that are stopwords of the language, minus the share of
+// words with letters foreign to its alphabet, plus the similarity of their
+// character n-gram rankings (the out-of-place measure of Cavnar and
+// Trenkle, 1994), with language profiles built from the embedded stopword
+// lists.
+type LanguageDetector struct {
+	languages []string
+	profiles  map[string]map[string]int
+	stopwords map[string]map[string]struct{}
+}
+
+// profileSize is the number of n-grams ranked per language profile.
+const profileSize = 300
+
+// NewLanguageDetector creates a detector for the given languages, all the
+// supported languages when none is given.
+func NewLanguageDetector(languages ...string) (*LanguageDetector, error) {
+	if len(languages) == 0 {
+		for lang := range supportedLanguages {
+			languages = append(languages, lang)
+		}
+	}
+	sort.Strings(languages)
+	d := &LanguageDetector{profiles: map[string]map[string]int{}, stopwords: map[string]map[string]struct{}{}}
+	for _, lang := range languages {
+		lang = strings.ToLower(lang)
+		words, err := getStopwords(lang)
+		if err != nil {
+			return nil, err
+		}
+		d.languages = append(d.languages, lang)
+		d.profiles[lang] = ngramRanks(Lowercasing(strings.Join(words, " ")))
+		d.stopwords[lang] = make(map[string]struct{}, len(words))
+		for _, word := range words {
+			d.stopwords[lang][Lowercasing(word)] = struct{}{}
+		}
+	}
+	return d, nil
+}
+
+// Languages returns the candidate languages of the detector.
+func (d *LanguageDetector) Languages() []string {
+	return d.languages
+}
+
+// Detect returns the most likely language of text and a confidence in
+// [0, 1], the relative score margin over the second best language. It
+// returns "" when text has no letters or is not written in the script of
+// any candidate.
+func (d *LanguageDetector) Detect(text string) (string, float64) {
+	text = Lowercasing(text)
+	candidates := d.candidates(text)
+	if len(candidates) == 0 {
+		return "", 0
+	}
+	if len(candidates) == 1 {
+		return candidates[0], 1
+	}
+
+	words := strings.FieldsFunc(text, isNotWordRune)
+	ranks := ngramRanks(text)
+	best, bestScore, secondScore := "", -1.0, -1.0
+	for _, lang := range candidates {
+		hits := 0
+		for _, word := range words {
+			if _, ok := d.stopwords[lang][word]; ok {
+				hits++
+			}
+		}
+		distance := 0
+		for gram, rank := range ranks {
+			if langRank, ok := d.profiles[lang][gram]; ok {
+				distance += abs(rank - langRank)
+			} else {
+				distance += profileSize
+			}
+		}
+		score := float64(hits-foreignWords(words, lang))/float64(len(words)) + 1 - float64(distance)/float64(profileSize*len(ranks))
+		if score > bestScore {
+			best, bestScore, secondScore = lang, score, bestScore
+		} else if score > secondScore {
+			secondScore = score
+		}
+	}
+	if bestScore <= 0 {
+		return best, 0
+	}
+	return best, (bestScore - max(secondScore, 0)) / bestScore
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+// candidates returns the languages written in the dominant script of text.
+func (d *LanguageDetector) candidates(text string) []string {
+	counts := make([]int, len(detectableScripts))
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		for i, script := range detectableScripts {
+			if unicode.Is(script, r) {
+				counts[i]++
+				break
+			}
+		}
+	}
+	dominant := 0
+	for i := range counts {
+		if counts[i] > counts[dominant] {
+			dominant = i
+		}
+	}
+	// Letters of other scripts, such as Thai or kana, match no candidate.
+	if counts[dominant] == 0 {
+		return nil
+	}
+	var candidates []string
+	for _, lang := range d.languages {
+		if languageScript(lang) == detectableScripts[dominant] {
+			candidates = append(candidates, lang)
+		}
+	}
+	return candidates
+}
+
+// ngramRanks ranks the profileSize most frequent n-grams of text, ties
+// broken alphabetically.
+func ngramRanks(text string) map[string]int {
+	counts := map[string]int{}
+	for _, gram := range charNgrams(text) {
+		counts[gram]++
+	}
+	grams := make([]string, 0, len(counts))
+	for gram := range counts {
+		grams = append(grams, gram)
+	}
+	sort.Slice(grams, func(i, j int) bool {
+		if counts[grams[i]] != counts[grams[j]] {
+			return counts[grams[i]] > counts[grams[j]]
+		}
+		return grams[i] < grams[j]
+	})
+	ranks := make(map[string]int, min(len(grams), profileSize))
+	for rank, gram := range grams[:min(len(grams), profileSize)] {
+		ranks[gram] = rank
+	}
+	return ranks
+}
+
+// charNgrams returns the character 1- to 3-grams of the words of text,
+// padded with spaces.
+func charNgrams(text string) []string {
+	var grams []string
+	for _, word := range strings.FieldsFunc(text, isNotWordRune) {
+		runes := []rune(" " + word + " ")
+		for n := 1; n <= 3; n++ {
+			for i := 0; i+n <= len(runes); i++ {
+				if n == 1 && runes[i] == ' ' {
+					continue
+				}
+				grams = append(grams, string(runes[i:i+n]))
+			}
+		}
+	}
+	return grams
+}
+
+func isNotWordRune(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsMark(r)
+}
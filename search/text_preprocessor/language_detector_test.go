@@ -0,0 +1,104 @@
+package text_preprocessor
+
+import "testing"
+
+func TestDetect(t *testing.T) {
+	detector, err := NewLanguageDetector()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"The dog sleeps on the sofa", "english"},
+		{"Le chat dort sur le canapé", "french"},
+		{"Der Hund schläft im Garten", "german"},
+		{"Il gatto dorme sul divano", "italian"},
+		{"El gato duerme en el sofá", "spanish"},
+		{"La casa es muy grande y bonita", "spanish"},
+		{"El gat dorm al sofà", "catalan"},
+		{"La casa és molt gran i bonica", "catalan"},
+		{"O gato dorme no sofá", "portuguese"},
+		{"Кошка спит на диване", "russian"},
+		{"Мысық диванда ұйықтап жатыр", "kazakh"},
+		{"Η γάτα κοιμάται στον καναπέ", "greek"},
+		{"القطة نائمة على الأريكة", "arabic"},
+		{"החתול ישן על הספה", "hebrew"},
+		{"猫在沙发上睡觉", "chinese"},
+		{"বিড়াল সোফায় ঘুমাচ্ছে", "bengali"},
+		{"बिरालो सोफामा सुतिरहेको छ", "nepali"},
+		// No letters, or only letters of scripts without candidates.
+		{"", ""},
+		{"1,000.50", ""},
+		{"สวัสดีครับ", ""},
+		{"こんにちは", ""},
+	}
+	for _, tt := range tests {
+		if got, _ := detector.Detect(tt.text); got != tt.want {
+			t.Errorf("Detect(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestDetectConfidence(t *testing.T) {
+	detector, err := NewLanguageDetector()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A single word says little about the language.
+	for _, text := range []string{"fox", "casa", "sofa", "running"} {
+		if _, confidence := detector.Detect(text); confidence > 0.15 {
+			t.Errorf("Detect(%q) confidence = %g, want at most 0.15", text, confidence)
+		}
+	}
+	if _, confidence := detector.Detect("The dog sleeps on the sofa"); confidence < 0.2 {
+		t.Errorf("sentence confidence = %g, want at least 0.2", confidence)
+	}
+	// A single candidate in the script is certain.
+	if lang, confidence := detector.Detect("החתול ישן"); lang != "hebrew" || confidence != 1 {
+		t.Errorf("Detect(hebrew) = %q, %g, want hebrew, 1", lang, confidence)
+	}
+	if lang, confidence := detector.Detect("สวัสดีครับ"); lang != "" || confidence != 0 {
+		t.Errorf("Detect(thai) = %q, %g, want no language", lang, confidence)
+	}
+}
+
+func TestDetectCandidates(t *testing.T) {
+	detector, err := NewLanguageDetector("English", "russian")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lang, _ := detector.Detect("Le chat dort"); lang != "english" {
+		t.Errorf("Detect(french) = %q, want the only Latin candidate english", lang)
+	}
+	if lang, _ := detector.Detect("Ο σκύλος"); lang != "" {
+		t.Errorf("Detect(greek) = %q, want no language", lang)
+	}
+	if _, err := NewLanguageDetector("klingon"); err == nil {
+		t.Error("detector for an unsupported language created")
+	}
+}
+
+// TestAlphabets checks that the stopwords of each Latin-script language
+// only use letters of its alphabet.
+func TestAlphabets(t *testing.T) {
+	for lang := range supportedLanguages {
+		if _, ok := languageScripts[lang]; ok {
+			continue
+		}
+		if _, ok := latinAlphabets[lang]; !ok {
+			t.Errorf("%s has no alphabet", lang)
+			continue
+		}
+		words, err := getStopwords(lang)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, word := range words {
+			if foreignWords([]string{Lowercasing(word)}, lang) > 0 {
+				t.Errorf("%s stopword %q has a letter outside the alphabet", lang, word)
+			}
+		}
+	}
+}
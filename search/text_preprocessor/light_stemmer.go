@@ -0,0 +1,180 @@
+package text_preprocessor
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// suffixRules describes a light stemmer: affixes are stripped, longest
+// first, as long as at least minStem runes remain. Agglutinative languages
+// use several passes to remove stacked suffixes.
+type suffixRules struct {
+	minStem  int
+	passes   int
+	prefixes []string
+	suffixes []string
+	// nativePrefixes matches the prefixes in their own script only. The
+	// one-letter prefixes of Hebrew and Arabic, transliterated, would strip
+	// the first letter of most words.
+	nativePrefixes bool
+}
+
+// lightStemmer builds a stemmer from rules. Text reaches the stemmer after
+// diacritic normalization, so the transliterated form of every affix is
+// matched too, unless the prefixes are native.
+func lightStemmer(rules suffixRules) StemmerFunc {
+	prefixes, suffixes := foldedAffixes(rules.prefixes), foldedAffixes(rules.suffixes)
+	if rules.nativePrefixes {
+		prefixes = byLength(slices.Clone(rules.prefixes))
+	}
+	passes := max(rules.passes, 1)
+	return func(word string) string {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(word, prefix) && utf8.RuneCountInString(word)-utf8.RuneCountInString(prefix) >= rules.minStem {
+				word = word[len(prefix):]
+				break
+			}
+		}
+		for pass := 0; pass < passes; pass++ {
+			stripped := false
+			for _, suffix := range suffixes {
+				if strings.HasSuffix(word, suffix) && utf8.RuneCountInString(word)-utf8.RuneCountInString(suffix) >= rules.minStem {
+					word = word[:len(word)-len(suffix)]
+					stripped = true
+					break
+				}
+			}
+			if !stripped {
+				break
+			}
+		}
+		return word
+	}
+}
+
+// foldedAffixes adds the transliterated forms of affixes and sorts them by
+// decreasing length.
+func foldedAffixes(affixes []string) []string {
+	seen := map[string]bool{}
+	var all []string
+	for _, affix := range affixes {
+		for _, form := range []string{affix, Lowercasing(strings.TrimSpace(NormalizeDiacritics(affix)))} {
+			if form != "" && !seen[form] {
+				seen[form] = true
+				all = append(all, form)
+			}
+		}
+	}
+	return byLength(all)
+}
+
+// byLength sorts affixes by decreasing length.
+func byLength(affixes []string) []string {
+	sort.SliceStable(affixes, func(i, j int) bool {
+		return utf8.RuneCountInString(affixes[i]) > utf8.RuneCountInString(affixes[j])
+	})
+	return affixes
+}
+
+// identityStemmer leaves words unchanged, for languages without inflection.
+func identityStemmer(word string) string {
+	return word
+}
+
+// lightStemmerRules holds the light stemmers of the languages the snowball
+// package does not cover. They follow the light stemmers of Lucene and the
+// step-1 suffix lists of the Snowball algorithms where they exist.
+var lightStemmerRules = map[string]suffixRules{
+	"arabic": {
+		minStem:        2,
+		prefixes:       []string{"وال", "بال", "كال", "فال", "لل", "ال", "و"},
+		suffixes:       []string{"ها", "ان", "ات", "ون", "ين", "يه", "ية", "ه", "ة", "ي"},
+		nativePrefixes: true,
+	},
+	"azerbaijani": {
+		minStem:  3,
+		passes:   2,
+		suffixes: []string{"ların", "lərin", "lar", "lər", "dan", "dən", "da", "də", "ın", "in", "un", "ün", "ı", "i", "u", "ü", "a", "ə"},
+	},
+	"basque": {
+		minStem:  3,
+		suffixes: []string{"arekin", "aren", "etan", "eko", "ean", "ari", "tik", "ak", "ek", "en", "ra", "ko", "a"},
+	},
+	"bengali": {
+		minStem:  2,
+		suffixes: []string{"গুলো", "গুলি", "দের", "রা", "ের", "কে", "তে", "টি", "টা", "র", "ে"},
+	},
+	"catalan": {
+		minStem:  3,
+		suffixes: []string{"ament", "cions", "ció", "itats", "itat", "ismes", "isme", "istes", "ista", "ar", "er", "ir", "es", "os", "a", "e", "o", "s"},
+	},
+	"danish": {
+		minStem:  3,
+		suffixes: []string{"erendes", "erende", "hedens", "ethed", "erede", "heden", "heder", "endes", "ernes", "erens", "erets", "ered", "ende", "erne", "eren", "erer", "heds", "enes", "eres", "eret", "hed", "ene", "ere", "ens", "ers", "ets", "en", "er", "es", "et", "e", "s"},
+	},
+	"dutch": {
+		minStem:  3,
+		suffixes: []string{"heden", "ingen", "heid", "lijk", "baar", "ende", "ing", "end", "en", "er", "e", "s"},
+	},
+	"finnish": {
+		minStem:  3,
+		passes:   2,
+		suffixes: []string{"kaan", "kään", "ssa", "ssä", "sta", "stä", "lla", "llä", "lta", "ltä", "lle", "ksi", "ine", "nsa", "nsä", "mme", "nne", "kin", "han", "hän", "ni", "si", "en", "an", "än", "in", "na", "nä", "ta", "tä", "a", "ä", "t"},
+	},
+	"german": {
+		minStem:  3,
+		suffixes: []string{"heiten", "keiten", "ungen", "heit", "keit", "ung", "ern", "em", "en", "er", "es", "e", "s"},
+	},
+	"greek": {
+		minStem:  3,
+		suffixes: []string{"ους", "ων", "ες", "ος", "ης", "ας", "ου", "α", "ε", "η", "ι", "ο", "υ", "ω"},
+	},
+	"hebrew": {
+		minStem:        2,
+		prefixes:       []string{"ו", "ה", "ב", "ל", "מ", "ש", "כ"},
+		suffixes:       []string{"ים", "ות", "יה", "ה"},
+		nativePrefixes: true,
+	},
+	"indonesian": {
+		minStem:  3,
+		passes:   3,
+		prefixes: []string{"meng", "meny", "men", "mem", "me", "peng", "peny", "pen", "pem", "di", "ter", "ke", "ber", "per", "se"},
+		suffixes: []string{"lah", "kah", "tah", "pun", "nya", "kan", "ku", "mu", "an", "i"},
+	},
+	"italian": {
+		minStem:  3,
+		suffixes: []string{"amente", "azione", "azioni", "issimo", "issima", "mente", "ando", "endo", "are", "ere", "ire", "ato", "ata", "ati", "ate", "ito", "ita", "iti", "ite", "i", "e", "o", "a"},
+	},
+	"kazakh": {
+		minStem:  3,
+		passes:   2,
+		suffixes: []string{"лардың", "лердің", "лар", "лер", "дар", "дер", "тар", "тер", "ның", "нің", "дың", "дің", "тың", "тің", "нан", "нен", "дан", "ден", "тан", "тен", "ға", "ге", "қа", "ке", "да", "де", "та", "те"},
+	},
+	"nepali": {
+		minStem:  2,
+		suffixes: []string{"हरूलाई", "हरूको", "देखि", "हरू", "लाई", "बाट", "सँग", "को", "का", "की", "ले", "मा"},
+	},
+	"portuguese": {
+		minStem:  3,
+		suffixes: []string{"amente", "mente", "ções", "ção", "ismo", "ista", "ados", "adas", "idos", "idas", "ando", "endo", "indo", "ado", "ada", "ido", "ida", "ar", "er", "ir", "os", "as", "es", "o", "a", "e", "s"},
+	},
+	"romanian": {
+		minStem:  3,
+		suffixes: []string{"urilor", "ului", "ilor", "elor", "ație", "atie", "ile", "ele", "ul", "ii", "le", "ea", "ei", "ia", "a", "e", "i", "u"},
+	},
+	"slovene": {
+		minStem:  3,
+		suffixes: []string{"ega", "emu", "ami", "ih", "im", "ov", "om", "ah", "a", "e", "i", "o", "u"},
+	},
+	"tajik": {
+		minStem:  3,
+		suffixes: []string{"ҳоро", "ҳои", "амон", "атон", "ашон", "ҳо", "он", "ро", "ам", "ат", "аш", "и", "ӣ"},
+	},
+	"turkish": {
+		minStem:  3,
+		passes:   2,
+		suffixes: []string{"lerin", "ların", "leri", "ları", "ler", "lar", "dır", "dir", "dur", "dür", "tır", "tir", "den", "dan", "ten", "tan", "nin", "nın", "nun", "nün", "de", "da", "te", "ta", "in", "ın", "un", "ün", "ye", "ya", "yi", "yı"},
+	},
+}
@@ -0,0 +1,70 @@
+package text_preprocessor
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestLightStemmers(t *testing.T) {
+	tests := []struct {
+		stemmer string
+		word    string
+		want    string
+	}{
+		{"hebrew", "והבית", "הבית"},
+		{"hebrew", "ספרים", "ספר"},
+		{"hebrew", "sprym", "spr"}, // Transliterated suffixes are stripped
+		{"hebrew", "vhbyt", "vhbyt"},
+		{"hebrew", "bait", "bait"}, // Not the prefix ב
+		{"arabic", "والكتاب", "كتاب"},
+		{"arabic", "المدرسة", "مدرس"},
+		{"arabic", "wlktb", "wlktb"},
+		{"arabic", "walad", "walad"}, // Not the prefix و
+		{"german", "hauser", "haus"},
+		{"german", "zeitungen", "zeit"},
+		{"italian", "velocemente", "veloce"},
+		{"turkish", "kitaplar", "kitap"},
+		{"finnish", "talossa", "talo"},
+		{"indonesian", "bermain", "main"},
+		{"chinese", "中文", "中文"},
+	}
+	for _, tt := range tests {
+		stemmer, err := GetStemmer(tt.stemmer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := stemmer(tt.word); got != tt.want {
+			t.Errorf("%s stemmer(%q) = %q, want %q", tt.stemmer, tt.word, got, tt.want)
+		}
+	}
+}
+
+// TestStemmerPerLanguage checks that every supported language has a stemmer.
+func TestStemmerPerLanguage(t *testing.T) {
+	for lang := range supportedLanguages {
+		if _, err := GetStemmer(lang); err != nil {
+			t.Errorf("%s: %v", lang, err)
+		}
+	}
+}
+
+// TestHebrewPreservedScript checks that Hebrew prefixes are stripped when
+// the script is kept, and not from its transliteration.
+func TestHebrewPreservedScript(t *testing.T) {
+	for _, tt := range []struct {
+		scripts []string
+		want    []string
+	}{
+		{[]string{"Hebrew"}, []string{"ספר"}},
+		{nil, []string{"vspr"}},
+	} {
+		config, err := NewConfig("whitespace", "auto", "hebrew")
+		if err != nil {
+			t.Fatal(err)
+		}
+		config.PreserveScripts = tt.scripts
+		if got := NewTextPreprocessor(config).Process("וספרים"); !slices.Equal(got, tt.want) {
+			t.Errorf("Process with scripts %q = %q, want %q", tt.scripts, got, tt.want)
+		}
+	}
+}
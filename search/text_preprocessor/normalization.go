@@ -3,6 +3,8 @@ package text_preprocessor
 import (
 	"regexp"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/rainycape/unidecode"
 )
@@ -29,6 +31,27 @@ func NormalizeDiacritics(text string) string {
 	return unidecode.Unidecode(text)
 }
 
+// NormalizeDiacriticsExcept is NormalizeDiacritics keeping the characters
+// of the given scripts, whose transliteration would destroy their meaning
+// (Chinese becomes pinyin-like fragments, Thai is dropped).
+func NormalizeDiacriticsExcept(scripts ...*unicode.RangeTable) func(string) string {
+	return func(text string) string {
+		var sb strings.Builder
+		start, kept := 0, false
+		for i, r := range text {
+			// Combining marks and kana marks stay with the kept character they follow
+			kept = unicode.In(r, scripts...) || (kept && (unicode.IsMark(r) || unicode.Is(kanaMarks, r)))
+			if kept {
+				sb.WriteString(unidecode.Unidecode(text[start:i]))
+				sb.WriteRune(r)
+				start = i + utf8.RuneLen(r)
+			}
+		}
+		sb.WriteString(unidecode.Unidecode(text[start:]))
+		return sb.String()
+	}
+}
+
 func NormalizeSpecialChars(text string) string {
 	return specialCharsTrans.Replace(text)
 }
@@ -15,15 +15,22 @@ type StemmerFunc func(string) string
 
 // Define a map to hold the stemmers
 var stemmersDict = map[string]StemmerFunc{
-	"porter":  porterStemmer,
-	"english": snowballStemmer("english"),
-	"french":  snowballStemmer("french"),
-	"german":  snowballStemmer("german"),
-	"spanish": snowballStemmer("spanish"),
-	"russian": snowballStemmer("russian"),
-	"swedish": snowballStemmer("swedish"),
-	"turkish": snowballStemmer("turkish"),
-	// Add more stemmers as needed
+	"porter":    porterStemmer,
+	"english":   snowballStemmer("english"),
+	"french":    snowballStemmer("french"),
+	"spanish":   snowballStemmer("spanish"),
+	"russian":   snowballStemmer("russian"),
+	"swedish":   snowballStemmer("swedish"),
+	"norwegian": snowballStemmer("norwegian"),
+	"hungarian": snowballStemmer("hungarian"),
+	"hinglish":  snowballStemmer("english"),
+	"chinese":   identityStemmer, // No inflection
+}
+
+func init() {
+	for language, rules := range lightStemmerRules {
+		stemmersDict[language] = lightStemmer(rules)
+	}
 }
 
 // Porter stemmer implementation
@@ -60,7 +67,8 @@ func snowballStemmer(language string) StemmerFunc {
 	}
 }
 
-// GetStemmer retrieves the appropriate stemmer function
+// GetStemmer retrieves the appropriate stemmer function. Every language of
+// supportedLanguages has one, from the snowball package or a light stemmer.
 func GetStemmer(stemmer string) (StemmerFunc, error) {
 	stemmer = strings.ToLower(stemmer)
 	if stemFunc, exists := stemmersDict[stemmer]; exists {
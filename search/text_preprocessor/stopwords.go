@@ -1,17 +1,21 @@
 package text_preprocessor
 
 import (
+	"embed"
 	"errors"
+	"io"
 	"strings"
 
 	"bufio"
 	"os"
-	"path/filepath"
 
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
 )
 
+//go:embed stopwords/*.txt
+var stopwordsFS embed.FS
+
 var supportedLanguages = map[string]struct{}{
 	"arabic":      {},
 	"azerbaijani": {},
@@ -50,17 +54,35 @@ func getStopwords(lang string) ([]string, error) {
 		return nil, errors.New("stop-words for " + cases.Title(language.Und).String(lang) + " are not available")
 	}
 
-	filename := filepath.Join("stopwords", lang+".txt")
-	file, err := os.Open(filename)
+	file, err := stopwordsFS.Open("stopwords/" + lang + ".txt")
 	if err != nil {
 		return nil, err
 	}
 	defer file.Close()
+	return LoadStopwords(file)
+}
+
+// LoadStopwords reads a stopword list with one word per line. Blank lines
+// and lines starting with # are skipped.
+func LoadStopwords(r io.Reader) ([]string, error) {
+	return readWordList(r)
+}
 
+// LoadDictionary reads a segmentation dictionary for NewDictionaryTokenizer,
+// in the same format as stopword lists.
+func LoadDictionary(r io.Reader) ([]string, error) {
+	return readWordList(r)
+}
+
+func readWordList(r io.Reader) ([]string, error) {
 	var stopwords []string
-	scanner := bufio.NewScanner(file)
+	scanner := bufio.NewScanner(r)
 	for scanner.Scan() {
-		stopwords = append(stopwords, scanner.Text())
+		word := strings.TrimSpace(scanner.Text())
+		if word == "" || strings.HasPrefix(word, "#") {
+			continue
+		}
+		stopwords = append(stopwords, word)
 	}
 
 	if err := scanner.Err(); err != nil {
@@ -70,12 +92,38 @@ func getStopwords(lang string) ([]string, error) {
 	return stopwords, nil
 }
 
+// LoadStopwordsFile reads a stopword list from a file, see LoadStopwords.
+func LoadStopwordsFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return LoadStopwords(file)
+}
+
+// stopwordSet builds the set of stopwords. Stopwords are removed after
+// diacritic normalization, so the transliterated form of each word is added
+// as well (unidecode capitalizes some scripts, such as Chinese).
+func stopwordSet(words []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(words))
+	for _, word := range words {
+		set[word] = struct{}{}
+		folded := strings.TrimSpace(NormalizeDiacritics(word))
+		set[folded] = struct{}{}
+		set[Lowercasing(folded)] = struct{}{}
+	}
+	return set
+}
+
 func GetStopwords(swList interface{}) ([]string, error) {
 	switch v := swList.(type) {
 	case string:
 		return getStopwords(v)
 	case []string:
 		return v, nil
+	case io.Reader:
+		return LoadStopwords(v)
 	case map[string]struct{}:
 		keys := make([]string, 0, len(v))
 		for k := range v {
@@ -0,0 +1,89 @@
+package text_preprocessor
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLoadStopwords(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"one per line", "the\na\nan", []string{"the", "a", "an"}},
+		{"blank lines and spaces", "\n  the  \n\n\ta\n", []string{"the", "a"}},
+		{"comments", "# English stopwords\nthe\n  # indented comment\na", []string{"the", "a"}},
+		{"windows line endings", "the\r\na\r\n", []string{"the", "a"}},
+		{"not a comment inside a word", "c#\nthe", []string{"c#", "the"}},
+		{"empty", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LoadStopwords(strings.NewReader(tt.text))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("LoadStopwords(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadStopwordsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stopwords.txt")
+	if err := os.WriteFile(path, []byte("# custom\nfoo\nbar\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadStopwordsFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"foo", "bar"}; !slices.Equal(got, want) {
+		t.Errorf("LoadStopwordsFile = %q, want %q", got, want)
+	}
+	if _, err := LoadStopwordsFile(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("LoadStopwordsFile of a missing file succeeded")
+	}
+}
+
+// TestEmbeddedStopwords checks that every supported language has a list
+// without comments or blank entries.
+func TestEmbeddedStopwords(t *testing.T) {
+	for lang := range supportedLanguages {
+		words, err := getStopwords(lang)
+		if err != nil {
+			t.Errorf("%s: %v", lang, err)
+			continue
+		}
+		if len(words) == 0 {
+			t.Errorf("%s: empty stopword list", lang)
+		}
+		for _, word := range words {
+			if word == "" || strings.HasPrefix(word, "#") {
+				t.Errorf("%s: stopword %q", lang, word)
+			}
+		}
+	}
+	if _, err := getStopwords("klingon"); err == nil {
+		t.Error("stopwords for an unsupported language loaded")
+	}
+}
+
+func TestSetStopwordsReader(t *testing.T) {
+	tokenizer, err := GetTokenizer("whitespace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tp := NewTextPreprocessor(&Config{Tokenizer: tokenizer, DoLowercasing: true})
+	if err := tp.SetStopwords(strings.NewReader("# custom\nthe\nquick\n")); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := tp.Process("the quick brown fox"), []string{"brown", "fox"}; !slices.Equal(got, want) {
+		t.Errorf("Process = %q, want %q", got, want)
+	}
+}
@@ -2,8 +2,11 @@ package text_preprocessor
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
+	"unicode"
+	"unicode/utf8"
 )
 
 // Config holds the configuration for text preprocessing.
@@ -16,10 +19,90 @@ type Config struct {
 	DoSpecialCharsNormalization bool
 	DoAcronymsNormalization     bool
 	DoPunctuationRemoval        bool
+	KeepDiacritics              bool     // Skip diacritic normalization, which is applied by default
+	KeepWhitespaces             bool     // Skip whitespace collapsing, for tokenizers relying on line breaks
+	Language                    string   // Language of the stopwords, used to pick language-specific defaults
+	PreserveScripts             []string // Scripts (names of unicode.Scripts) kept as is by diacritic normalization, see CJKScripts
+	// Detector, when set, detects the language of each text, which is then
+	// analysed with the stopwords and stemmer of that language.
+	Detector *LanguageDetector
+	// Analyzer, when set, replaces the chain built from the fields above,
+	// for every language.
+	Analyzer *Analyzer
+}
+
+// ConfigDefinition holds the fields of a Config that can be stored with an
+// index. Functions cannot: the tokenizer and stemmer are those of the Config
+// it is applied to.
+type ConfigDefinition struct {
+	Language        string   `json:"language,omitempty"`
+	Stopwords       []string `json:"stopwords,omitempty"`
+	Lowercasing     bool     `json:"lowercasing,omitempty"`
+	Ampersand       bool     `json:"ampersand,omitempty"`
+	SpecialChars    bool     `json:"special_chars,omitempty"`
+	Acronyms        bool     `json:"acronyms,omitempty"`
+	Punctuation     bool     `json:"punctuation,omitempty"`
+	KeepDiacritics  bool     `json:"keep_diacritics,omitempty"`
+	KeepWhitespaces bool     `json:"keep_whitespaces,omitempty"`
+	PreserveScripts []string `json:"preserve_scripts,omitempty"`
+	Languages       []string `json:"languages,omitempty"` // Candidates of the language detector
+}
+
+// Definition returns the storable fields of the configuration.
+func (c Config) Definition() ConfigDefinition {
+	def := ConfigDefinition{
+		Language:        c.Language,
+		Lowercasing:     c.DoLowercasing,
+		Ampersand:       c.DoAmpersandNormalization,
+		SpecialChars:    c.DoSpecialCharsNormalization,
+		Acronyms:        c.DoAcronymsNormalization,
+		Punctuation:     c.DoPunctuationRemoval,
+		KeepDiacritics:  c.KeepDiacritics,
+		KeepWhitespaces: c.KeepWhitespaces,
+		PreserveScripts: append([]string(nil), c.PreserveScripts...),
+	}
+	for word := range c.Stopwords {
+		def.Stopwords = append(def.Stopwords, word)
+	}
+	sort.Strings(def.Stopwords)
+	if c.Detector != nil {
+		def.Languages = append([]string(nil), c.Detector.Languages()...)
+	}
+	return def
+}
+
+// Apply sets the fields of config described by the definition.
+func (def ConfigDefinition) Apply(config *Config) error {
+	config.Language = def.Language
+	config.Stopwords = make(map[string]struct{}, len(def.Stopwords))
+	for _, word := range def.Stopwords {
+		config.Stopwords[word] = struct{}{}
+	}
+	config.DoLowercasing = def.Lowercasing
+	config.DoAmpersandNormalization = def.Ampersand
+	config.DoSpecialCharsNormalization = def.SpecialChars
+	config.DoAcronymsNormalization = def.Acronyms
+	config.DoPunctuationRemoval = def.Punctuation
+	config.KeepDiacritics = def.KeepDiacritics
+	config.KeepWhitespaces = def.KeepWhitespaces
+	config.PreserveScripts = append([]string(nil), def.PreserveScripts...)
+	config.Detector = nil
+	if len(def.Languages) > 0 {
+		detector, err := NewLanguageDetector(def.Languages...)
+		if err != nil {
+			return err
+		}
+		config.Detector = detector
+	}
+	return nil
 }
 
 // NewConfig creates a new Config with the specified tokenizer, stemmer, and stopwords.
+// The "auto" stemmer picks the stemmer of lang.
 func NewConfig(tokenizer string, stemmer string, lang string) (*Config, error) {
+	if strings.EqualFold(stemmer, "auto") {
+		stemmer = lang
+	}
 	tokenizerFunc, err := GetTokenizer(tokenizer)
 	if err != nil {
 		return nil, fmt.Errorf("error getting tokenizer: %w", err)
@@ -38,7 +121,10 @@ func NewConfig(tokenizer string, stemmer string, lang string) (*Config, error) {
 		DoAmpersandNormalization:    true,
 		DoSpecialCharsNormalization: true,
 		DoAcronymsNormalization:     false,
-		DoPunctuationRemoval:        true,
+		// The uax29 tokenizer handles punctuation itself, keeping numbers,
+		// emails and URLs whole.
+		DoPunctuationRemoval: !strings.EqualFold(tokenizer, "uax29"),
+		Language:             lang,
 	}
 
 	stopwords, err := GetStopwords(lang)
@@ -46,63 +132,276 @@ func NewConfig(tokenizer string, stemmer string, lang string) (*Config, error) {
 		return nil, fmt.Errorf("error getting stopwords: %w", err)
 	}
 
-	for _, word := range stopwords {
-		config.Stopwords[word] = struct{}{}
+	config.Stopwords = stopwordSet(stopwords)
+
+	return config, nil
+}
+
+// NewAnalyzerConfig creates a Config analysing text with the named analyzer,
+// lang being used for language-specific defaults such as prompts.
+func NewAnalyzerConfig(analyzer string, lang string) (*Config, error) {
+	a, err := GetAnalyzer(analyzer)
+	if err != nil {
+		return nil, fmt.Errorf("error getting analyzer: %w", err)
 	}
+	tokenizer, err := getTokenizer(a.definition.Tokenizer)
+	if err != nil {
+		return nil, fmt.Errorf("error getting tokenizer: %w", err)
+	}
+	return &Config{Tokenizer: tokenizer, Language: lang, Analyzer: a}, nil
+}
 
+// NewMultilingualConfig creates a Config detecting the language of each text
+// among languages, the first one being used when detection fails.
+func NewMultilingualConfig(tokenizer string, languages ...string) (*Config, error) {
+	if len(languages) == 0 {
+		return nil, fmt.Errorf("no language given")
+	}
+	config, err := NewConfig(tokenizer, "auto", languages[0])
+	if err != nil {
+		return nil, err
+	}
+	config.Detector, err = NewLanguageDetector(languages...)
+	if err != nil {
+		return nil, fmt.Errorf("error creating language detector: %w", err)
+	}
 	return config, nil
 }
 
-// TextPreprocessor holds the preprocessing steps and configuration.
+// TextPreprocessor holds the analyzer and configuration.
 type TextPreprocessor struct {
-	config *Config
-	steps  []func(string) string
+	config    *Config
+	analyzer  *Analyzer
+	languages map[string]*TextPreprocessor // Per-language preprocessors, when languages are detected
 }
 
 // NewTextPreprocessor creates a new TextPreprocessor with the given configuration.
 func NewTextPreprocessor(config *Config) *TextPreprocessor {
 	tp := &TextPreprocessor{config: config}
-	tp.createPreprocessingSteps()
+	tp.createAnalyzer()
+	if config.Detector != nil && config.Analyzer == nil {
+		tp.languages = map[string]*TextPreprocessor{}
+		for _, lang := range config.Detector.Languages() {
+			langConfig := *config
+			langConfig.Detector = nil
+			langConfig.Language = lang
+			if stopwords, err := getStopwords(lang); err == nil {
+				langConfig.Stopwords = stopwordSet(stopwords)
+			}
+			if stemmer, err := GetStemmer(lang); err == nil {
+				langConfig.Stemmer = stemmer
+			}
+			tp.languages[lang] = NewTextPreprocessor(&langConfig)
+		}
+	}
 	return tp
 }
 
-// createPreprocessingSteps creates the preprocessing steps based on the configuration.
-func (tp *TextPreprocessor) createPreprocessingSteps() {
+// DetectLanguage returns the detected language of text, "" when language
+// detection is off or fails.
+func (tp *TextPreprocessor) DetectLanguage(text string) string {
+	if tp.config.Detector == nil {
+		return ""
+	}
+	lang, _ := tp.config.Detector.Detect(text)
+	return lang
+}
+
+// Language returns the language of the configuration.
+func (tp *TextPreprocessor) Language() string {
+	return tp.config.Language
+}
+
+// Config returns a copy of the configuration.
+func (tp *TextPreprocessor) Config() Config {
+	return *tp.config
+}
+
+// Analyzer returns the analyzer of the configuration.
+func (tp *TextPreprocessor) Analyzer() *Analyzer {
+	return tp.analyzer
+}
+
+// createAnalyzer builds the analyzer of the configuration: its Analyzer when
+// set, a chain following the Do* flags otherwise, with stopword removal and
+// stemming applied to the tokens.
+func (tp *TextPreprocessor) createAnalyzer() {
+	if tp.config.Analyzer != nil {
+		tp.analyzer = tp.config.Analyzer
+		return
+	}
+	a := &Analyzer{tokenizer: tp.config.Tokenizer}
 	if tp.config.DoLowercasing {
-		tp.steps = append(tp.steps, Lowercasing)
+		a.charFilters = append(a.charFilters, Lowercasing)
 	}
 	if tp.config.DoAmpersandNormalization {
-		tp.steps = append(tp.steps, NormalizeAmpersand)
+		a.charFilters = append(a.charFilters, NormalizeAmpersand)
 	}
 	if tp.config.DoSpecialCharsNormalization {
-		tp.steps = append(tp.steps, NormalizeSpecialChars)
+		a.charFilters = append(a.charFilters, NormalizeSpecialChars)
 	}
 	if tp.config.DoAcronymsNormalization {
-		tp.steps = append(tp.steps, NormalizeAcronyms)
+		a.charFilters = append(a.charFilters, NormalizeAcronyms)
 	}
 	if tp.config.DoPunctuationRemoval {
-		tp.steps = append(tp.steps, RemovePunctuation)
+		a.charFilters = append(a.charFilters, RemovePunctuation)
+	}
+	if !tp.config.KeepDiacritics {
+		if len(tp.config.PreserveScripts) > 0 {
+			a.charFilters = append(a.charFilters, NormalizeDiacriticsExcept(preservedScripts(tp.config.PreserveScripts)...))
+		} else {
+			a.charFilters = append(a.charFilters, NormalizeDiacritics)
+		}
+	}
+	if !tp.config.KeepWhitespaces {
+		a.charFilters = append(a.charFilters, StripWhitespaces)
 	}
-	tp.steps = append(tp.steps, NormalizeDiacritics)
-	tp.steps = append(tp.steps, StripWhitespaces)
-	// Remove tokenizer from tp.steps
 	if len(tp.config.Stopwords) > 0 {
-		tp.steps = append(tp.steps, tp.removeStopwords)
+		a.tokenFilters = append(a.tokenFilters, stopwordsFilter(tp.config.Stopwords))
 	}
 	if tp.config.Stemmer != nil {
-		tp.steps = append(tp.steps, tp.applyStemmer)
+		a.tokenFilters = append(a.tokenFilters, stemmerFilter(tp.config.Stemmer))
 	}
+	tp.analyzer = a
 }
 
 // Process processes a single text item through all preprocessing steps.
 func (tp *TextPreprocessor) Process(item string) []string {
-	for _, step := range tp.steps {
-		item = step(item)
+	return tp.ProcessLanguage(item, tp.DetectLanguage(item))
+}
+
+// ProcessLanguage processes item with the stopwords and stemmer of lang when
+// languages are detected, with the base configuration otherwise.
+func (tp *TextPreprocessor) ProcessLanguage(item string, lang string) []string {
+	if sub, ok := tp.languages[lang]; ok {
+		return sub.process(item)
+	}
+	return tp.process(item)
+}
+
+func (tp *TextPreprocessor) process(item string) []string {
+	return tp.analyzer.Analyze(item)
+}
+
+// Token is a processed token with the byte offsets [Start, End) of the
+// original word it comes from.
+type Token struct {
+	Text  string
+	Start int
+	End   int
+}
+
+// ProcessWithOffsets processes text like Process, keeping for each token the
+// offsets of its word in text.
+func (tp *TextPreprocessor) ProcessWithOffsets(text string) []Token {
+	return tp.ProcessLanguageWithOffsets(text, tp.DetectLanguage(text))
+}
+
+// ProcessLanguageWithOffsets processes text like ProcessLanguage, keeping for
+// each token the offsets of its word in text. The whitespace-separated chunks
+// of text are analysed one at a time, so tokenizers joining words across
+// whitespace (sentences) are not supported. The tokens of a chunk get the
+// offsets of the words the tokenizer finds in it when analysing these words
+// one by one gives the same tokens, the offsets of the whole chunk without
+// its surrounding punctuation otherwise.
+func (tp *TextPreprocessor) ProcessLanguageWithOffsets(text string, lang string) []Token {
+	if sub, ok := tp.languages[lang]; ok {
+		return sub.processWithOffsets(text)
 	}
-	// Apply tokenizer separately
-	tokens := tp.config.Tokenizer(item)
-	finalTokens := RemoveEmptyTokens(tokens)
-	return finalTokens
+	return tp.processWithOffsets(text)
+}
+
+func (tp *TextPreprocessor) processWithOffsets(text string) []Token {
+	var tokens []Token
+	for _, chunk := range chunkSpans(text) {
+		chunkTokens := tp.process(text[chunk[0]:chunk[1]])
+		if len(chunkTokens) == 0 {
+			continue
+		}
+		if words, ok := tp.wordTokens(text, chunk); ok && sameTexts(words, chunkTokens) {
+			tokens = append(tokens, words...)
+			continue
+		}
+		start, end := trimPunctuation(text, chunk[0], chunk[1])
+		for _, token := range chunkTokens {
+			tokens = append(tokens, Token{Text: token, Start: start, End: end})
+		}
+	}
+	return tokens
+}
+
+// wordTokens splits the chunk of text with the tokenizer and analyses each
+// word on its own, false when a word cannot be found in the chunk.
+func (tp *TextPreprocessor) wordTokens(text string, chunk [2]int) ([]Token, bool) {
+	var tokens []Token
+	// Words are searched from the start of the previous one, so overlapping
+	// words (bigrams, hyphenated compounds and their parts) are found too.
+	previous := [2]int{chunk[0], chunk[0]}
+	for _, word := range tp.analyzer.tokenizer(text[chunk[0]:chunk[1]]) {
+		if word == "" {
+			continue
+		}
+		from := previous[0]
+		i := strings.Index(text[from:chunk[1]], word)
+		if i >= 0 && from+i == previous[0] && from+i+len(word) == previous[1] {
+			_, size := utf8.DecodeRuneInString(text[from:])
+			from += size
+			i = strings.Index(text[from:chunk[1]], word)
+		}
+		if i < 0 {
+			return nil, false
+		}
+		previous = [2]int{from + i, from + i + len(word)}
+		for _, token := range tp.process(word) {
+			tokens = append(tokens, Token{Text: token, Start: previous[0], End: previous[1]})
+		}
+	}
+	return tokens, true
+}
+
+// chunkSpans returns the offsets of the whitespace-separated chunks of text.
+func chunkSpans(text string) [][2]int {
+	var spans [][2]int
+	start := -1
+	for i, r := range text {
+		if unicode.IsSpace(r) {
+			if start >= 0 {
+				spans = append(spans, [2]int{start, i})
+				start = -1
+			}
+		} else if start < 0 {
+			start = i
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, [2]int{start, len(text)})
+	}
+	return spans
+}
+
+// trimPunctuation narrows [start, end) to its first and last letter, digit
+// or mark, leaving it as is when it has none.
+func trimPunctuation(text string, start, end int) (int, int) {
+	isWord := func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r) }
+	i := strings.IndexFunc(text[start:end], isWord)
+	if i < 0 {
+		return start, end
+	}
+	j := strings.LastIndexFunc(text[start:end], isWord)
+	_, size := utf8.DecodeRuneInString(text[start+j:])
+	return start + i, start + j + size
+}
+
+func sameTexts(tokens []Token, texts []string) bool {
+	if len(tokens) != len(texts) {
+		return false
+	}
+	for i, token := range tokens {
+		if token.Text != texts[i] {
+			return false
+		}
+	}
+	return true
 }
 
 // ProcessMany processes multiple text items concurrently.
@@ -136,22 +435,6 @@ func (tp *TextPreprocessor) ProcessMany(items []string, nWorkers int) [][]string
 	return out
 }
 
-// Helper functions for preprocessing steps can be removed as they are now in normalization.go
-
-// Update removeStopwords to use the RemoveStopwords function from stopwords.go
-func (tp *TextPreprocessor) removeStopwords(s string) string {
-	tokens := strings.Fields(s)
-	filteredTokens := RemoveStopwords(tokens, tp.config.Stopwords)
-	return strings.Join(filteredTokens, " ")
-}
-
-// Update applyStemmer to use the ApplyStemmer function from stemmer.go
-func (tp *TextPreprocessor) applyStemmer(s string) string {
-	tokens := strings.Fields(s)
-	stemmedTokens := ApplyStemmer(tokens, tp.config.Stemmer)
-	return strings.Join(stemmedTokens, " ")
-}
-
 // Add a method to set the stemmer
 func (tp *TextPreprocessor) SetStemmer(stemmerName string) error {
 	stemmer, err := GetStemmer(stemmerName)
@@ -159,7 +442,7 @@ func (tp *TextPreprocessor) SetStemmer(stemmerName string) error {
 		return err
 	}
 	tp.config.Stemmer = stemmer
-	tp.createPreprocessingSteps() // Recreate steps to include the stemmer
+	tp.createAnalyzer() // Recreate the analyzer to include the stemmer
 	return nil
 }
 
@@ -169,10 +452,7 @@ func (tp *TextPreprocessor) SetStopwords(stopwords interface{}) error {
 	if err != nil {
 		return err
 	}
-	tp.config.Stopwords = make(map[string]struct{})
-	for _, word := range stopwordsList {
-		tp.config.Stopwords[word] = struct{}{}
-	}
-	tp.createPreprocessingSteps() // Recreate steps to include stopwords removal
+	tp.config.Stopwords = stopwordSet(stopwordsList)
+	tp.createAnalyzer() // Recreate the analyzer to include stopwords removal
 	return nil
 }
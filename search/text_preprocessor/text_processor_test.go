@@ -0,0 +1,145 @@
+package text_preprocessor
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestProcessWithOffsets(t *testing.T) {
+	cjk, err := NewAnalyzer(AnalyzerDefinition{Name: "cjk", CharFilters: []string{"lowercase"}, Tokenizer: "cjk"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	simple, err := GetAnalyzer("simple")
+	if err != nil {
+		t.Fatal(err)
+	}
+	standard, err := GetAnalyzer("standard")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		analyzer *Analyzer
+		text     string
+		want     []Token
+	}{
+		{
+			name:     "punctuation around words",
+			analyzer: simple,
+			text:     "(Fox), dog!",
+			want:     []Token{{"fox", 1, 4}, {"dog", 7, 10}},
+		},
+		{
+			name:     "email kept whole",
+			analyzer: simple,
+			text:     "mail foo@bar.com now",
+			want:     []Token{{"mail", 0, 4}, {"foo@bar.com", 5, 16}, {"now", 17, 20}},
+		},
+		{
+			name:     "cjk bigrams",
+			analyzer: cjk,
+			text:     "東京都 fox",
+			want:     []Token{{"東京", 0, 6}, {"京都", 3, 9}, {"fox", 10, 13}},
+		},
+		{
+			name:     "stemmed words and stopwords",
+			analyzer: standard,
+			text:     "The running dogs",
+			want:     []Token{{"run", 4, 11}, {"dog", 12, 16}},
+		},
+		{
+			// The word tokenizer only finds "caf", the chunk is the word.
+			name:     "char filters changing words",
+			analyzer: standard,
+			text:     "Café!",
+			want:     []Token{{"cafe", 0, 5}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tp := NewTextPreprocessor(&Config{Analyzer: tt.analyzer})
+			got := tp.ProcessWithOffsets(tt.text)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ProcessWithOffsets(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+			texts := make([]string, len(got))
+			for i, token := range got {
+				texts[i] = token.Text
+			}
+			if want := tp.Process(tt.text); !slices.Equal(texts, want) {
+				t.Errorf("tokens = %q, want the tokens of Process %q", texts, want)
+			}
+		})
+	}
+}
+
+// TestProcessLanguageWithOffsets checks that the tokens are those of the
+// given language rather than of the detected one.
+func TestProcessLanguageWithOffsets(t *testing.T) {
+	config, err := NewMultilingualConfig("word", "english", "french")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tp := NewTextPreprocessor(config)
+	text := "les chats"
+	got := tp.ProcessLanguageWithOffsets(text, "french")
+	want := tp.ProcessLanguage(text, "french")
+	if len(got) != len(want) {
+		t.Fatalf("ProcessLanguageWithOffsets = %v, want the tokens %q", got, want)
+	}
+	for i, token := range got {
+		if token.Text != want[i] {
+			t.Errorf("token %d = %q, want %q", i, token.Text, want[i])
+		}
+	}
+	if english := tp.ProcessLanguage(text, "english"); slices.Equal(english, want) {
+		t.Fatalf("english and french tokens of %q are both %q, the test needs them to differ", text, want)
+	}
+}
+
+func TestConfigDefinition(t *testing.T) {
+	config, err := NewMultilingualConfig("word", "english", "french")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.KeepDiacritics = true
+	config.PreserveScripts = CJKScripts
+	def := config.Definition()
+
+	applied := Config{}
+	if err := def.Apply(&applied); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(applied.Definition(), def) {
+		t.Errorf("definition after Apply = %+v, want %+v", applied.Definition(), def)
+	}
+	if applied.Detector == nil || !slices.Equal(applied.Detector.Languages(), []string{"english", "french"}) {
+		t.Errorf("detector not restored")
+	}
+	if _, ok := applied.Stopwords["the"]; !ok || len(applied.Stopwords) != len(config.Stopwords) {
+		t.Errorf("%d stopwords restored, want the %d english ones", len(applied.Stopwords), len(config.Stopwords))
+	}
+	if err := (ConfigDefinition{Languages: []string{"klingon"}}).Apply(&applied); err == nil {
+		t.Error("Apply with an unsupported language succeeded")
+	}
+}
+
+func TestKeepWhitespaces(t *testing.T) {
+	lines := func(text string) []string { return strings.Split(text, "\n") }
+	tests := []struct {
+		keep bool
+		want []string
+	}{
+		{false, []string{"a b c"}},
+		{true, []string{"a  b", "c"}},
+	}
+	for _, tt := range tests {
+		tp := NewTextPreprocessor(&Config{Tokenizer: lines, KeepWhitespaces: tt.keep})
+		if got := tp.Process("a  b\nc"); !slices.Equal(got, tt.want) {
+			t.Errorf("KeepWhitespaces %v: Process = %q, want %q", tt.keep, got, tt.want)
+		}
+	}
+}
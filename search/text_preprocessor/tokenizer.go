@@ -15,6 +15,16 @@ var tokenizersDict = map[string]TokenizerFunc{
 	"word":       wordTokenizer,
 	"wordpunct":  wordPunctTokenizer,
 	"sent":       sentenceTokenizer,
+	"cjk":        cjkTokenizer,
+	"uax29":      uax29Tokenizer,
+}
+
+// RegisterTokenizer registers a tokenizer under name, so that analyzer
+// definitions can refer to it.
+func RegisterTokenizer(name string, tokenizer TokenizerFunc) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	tokenizersDict[strings.ToLower(name)] = tokenizer
 }
 
 // wordTokenizer tokenizes text into words.
@@ -38,7 +48,10 @@ func sentenceTokenizer(text string) []string {
 // getTokenizer returns the tokenizer function based on the provided name.
 func getTokenizer(tokenizer string) (TokenizerFunc, error) {
 	tokenizer = strings.ToLower(tokenizer)
-	if fn, exists := tokenizersDict[tokenizer]; exists {
+	registryMu.RLock()
+	fn, exists := tokenizersDict[tokenizer]
+	registryMu.RUnlock()
+	if exists {
 		return fn, nil
 	}
 	return nil, errors.New("tokenizer " + tokenizer + " not supported")
@@ -0,0 +1,319 @@
+package text_preprocessor
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// wordBreak is the Word_Break property of UAX #29, approximated from the
+// general categories and scripts of the unicode package.
+type wordBreak int
+
+const (
+	wbOther wordBreak = iota
+	wbCR
+	wbLF
+	wbNewline
+	wbExtend
+	wbZWJ
+	wbRegionalIndicator
+	wbFormat
+	wbKatakana
+	wbHebrewLetter
+	wbALetter
+	wbSingleQuote
+	wbDoubleQuote
+	wbMidNumLet
+	wbMidLetter
+	wbMidNum
+	wbNumeric
+	wbExtendNumLet
+	wbWSegSpace
+)
+
+func wordBreakOf(r rune) wordBreak {
+	switch r {
+	case '\r':
+		return wbCR
+	case '\n':
+		return wbLF
+	case '\v', '\f', 0x85, 0x2028, 0x2029:
+		return wbNewline
+	case 0x200D:
+		return wbZWJ
+	case '\'':
+		return wbSingleQuote
+	case '"':
+		return wbDoubleQuote
+	case '.', 0x2018, 0x2019, 0x2024, 0xFE52, 0xFF07, 0xFF0E:
+		return wbMidNumLet
+	case ':', 0x00B7, 0x0387, 0x055F, 0x05F4, 0x2027, 0xFE13, 0xFE55, 0xFF1A:
+		return wbMidLetter
+	case ',', ';', 0x037E, 0x0589, 0x060C, 0x060D, 0x066C, 0x07F8, 0x2044, 0xFE10, 0xFE14, 0xFE50, 0xFE54, 0xFF0C, 0xFF1B:
+		return wbMidNum
+	case 0x202F:
+		return wbExtendNumLet
+	case 0x30FC, 0x3031, 0x3032, 0x3033, 0x3034, 0x3035, 0x309B, 0x309C, 0x30A0, 0xFF70:
+		return wbKatakana
+	}
+	switch {
+	case r >= 0x1F1E6 && r <= 0x1F1FF:
+		return wbRegionalIndicator
+	case unicode.Is(unicode.Katakana, r):
+		return wbKatakana
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc):
+		return wbExtend
+	case unicode.Is(unicode.Cf, r):
+		return wbFormat
+	case unicode.Is(unicode.Hebrew, r) && unicode.IsLetter(r):
+		return wbHebrewLetter
+	case unicode.IsLetter(r) && !unicode.In(r, unicode.Han, unicode.Hiragana) && !isSegmented(r):
+		return wbALetter
+	case unicode.Is(unicode.Nd, r):
+		return wbNumeric
+	case unicode.Is(unicode.Pc, r):
+		return wbExtendNumLet
+	case unicode.Is(unicode.Zs, r):
+		return wbWSegSpace
+	}
+	return wbOther
+}
+
+func isAHLetter(wb wordBreak) bool { return wb == wbALetter || wb == wbHebrewLetter }
+
+func isMidNumLetQ(wb wordBreak) bool { return wb == wbMidNumLet || wb == wbSingleQuote }
+
+func isIgnored(wb wordBreak) bool { return wb == wbExtend || wb == wbFormat || wb == wbZWJ }
+
+// uax29Segments splits text at the word boundaries of UAX #29 (rules WB1 to
+// WB999, without the emoji rule WB3c).
+func uax29Segments(text string) []string {
+	runes := []rune(text)
+	props := make([]wordBreak, len(runes))
+	for i, r := range runes {
+		props[i] = wordBreakOf(r)
+	}
+	// next returns the index of the first non-ignored character after i (WB4)
+	next := func(i int) int {
+		for i++; i < len(runes) && isIgnored(props[i]); i++ {
+		}
+		return i
+	}
+	prop := func(i int) wordBreak {
+		if i < 0 || i >= len(runes) {
+			return wbOther
+		}
+		return props[i]
+	}
+
+	if len(runes) == 0 {
+		return nil
+	}
+	var segments []string
+	start := 0
+	// prev and prevPrev are the last two characters before the candidate
+	// boundary, ignored ones skipped (WB4); riCount counts the regional
+	// indicators in a row up to prev.
+	prev, prevPrev, riCount := 0, -1, 0
+	if props[0] == wbRegionalIndicator {
+		riCount = 1
+	}
+	for i := 1; i < len(runes); i++ {
+		if wordBoundaryAt(props, i, prev, prevPrev, riCount, next, prop) {
+			segments = append(segments, string(runes[start:i]))
+			start = i
+		}
+		if isIgnored(props[i]) && !isNewline(props[i-1]) {
+			continue
+		}
+		if props[i] == wbRegionalIndicator {
+			riCount++
+		} else {
+			riCount = 0
+		}
+		prevPrev, prev = prev, i
+	}
+	return append(segments, string(runes[start:]))
+}
+
+func isNewline(wb wordBreak) bool { return wb == wbCR || wb == wbLF || wb == wbNewline }
+
+// wordBoundaryAt reports whether there is a word boundary before runes[i].
+func wordBoundaryAt(props []wordBreak, i, prev, prevPrev, riCount int, next func(int) int, prop func(int) wordBreak) bool {
+	before, after := props[i-1], props[i]
+	// WB3, WB3a, WB3b
+	if before == wbCR && after == wbLF {
+		return false
+	}
+	if isNewline(before) || isNewline(after) {
+		return true
+	}
+	// WB3d
+	if before == wbWSegSpace && after == wbWSegSpace {
+		return false
+	}
+	// WB4
+	if isIgnored(after) {
+		return false
+	}
+	b, a := prop(prev), after
+	bb, aa := prop(prevPrev), prop(next(i))
+	switch {
+	case isAHLetter(b) && isAHLetter(a): // WB5
+		return false
+	case isAHLetter(b) && (a == wbMidLetter || isMidNumLetQ(a)) && isAHLetter(aa): // WB6
+		return false
+	case isAHLetter(bb) && (b == wbMidLetter || isMidNumLetQ(b)) && isAHLetter(a): // WB7
+		return false
+	case b == wbHebrewLetter && a == wbSingleQuote: // WB7a
+		return false
+	case b == wbHebrewLetter && a == wbDoubleQuote && aa == wbHebrewLetter: // WB7b
+		return false
+	case bb == wbHebrewLetter && b == wbDoubleQuote && a == wbHebrewLetter: // WB7c
+		return false
+	case b == wbNumeric && a == wbNumeric: // WB8
+		return false
+	case isAHLetter(b) && a == wbNumeric: // WB9
+		return false
+	case b == wbNumeric && isAHLetter(a): // WB10
+		return false
+	case bb == wbNumeric && (b == wbMidNum || isMidNumLetQ(b)) && a == wbNumeric: // WB11
+		return false
+	case b == wbNumeric && (a == wbMidNum || isMidNumLetQ(a)) && aa == wbNumeric: // WB12
+		return false
+	case b == wbKatakana && a == wbKatakana: // WB13
+		return false
+	case (isAHLetter(b) || b == wbNumeric || b == wbKatakana || b == wbExtendNumLet) && a == wbExtendNumLet: // WB13a
+		return false
+	case b == wbExtendNumLet && (isAHLetter(a) || a == wbNumeric || a == wbKatakana): // WB13b
+		return false
+	case b == wbRegionalIndicator && a == wbRegionalIndicator && riCount%2 == 1: // WB15, WB16
+		return false
+	}
+	return true // WB999
+}
+
+type HyphenMode int
+
+const (
+	// HyphenSplit emits the parts of hyphenated compounds, as UAX #29 does.
+	HyphenSplit HyphenMode = iota
+	// HyphenKeep emits hyphenated compounds as single tokens.
+	HyphenKeep
+	// HyphenBoth emits the compound followed by its parts.
+	HyphenBoth
+)
+
+// UAX29Options configures NewUAX29Tokenizer.
+type UAX29Options struct {
+	Hyphens   HyphenMode
+	SplitURLs bool // Split URLs and emails into words instead of keeping them whole
+	// Elisions drops elided articles and pronouns, as in French or
+	// Italian: "l'homme" gives "homme", "qu'il" gives "il".
+	Elisions bool
+}
+
+var (
+	urlPattern   = regexp.MustCompile(`(?i)\b(?:[a-z][a-z0-9+.-]*://|www\.)[^\s<>"]+`)
+	emailPattern = regexp.MustCompile(`[\p{L}\p{N}._%+-]+@[\p{L}\p{N}-]+(?:\.[\p{L}\p{N}-]+)*\.\p{L}{2,}`)
+)
+
+// NewUAX29Tokenizer returns a tokenizer splitting text at the word
+// boundaries of Unicode text segmentation (UAX #29). Only segments holding
+// a letter or a digit are tokens, so "naïve", "Straße", "don't" and "3.14"
+// stay whole; URLs and emails are kept as single tokens.
+func NewUAX29Tokenizer(opts UAX29Options) TokenizerFunc {
+	return func(text string) []string {
+		var tokens []string
+		last := 0
+		if !opts.SplitURLs {
+			for _, span := range linkSpans(text) {
+				tokens = append(tokens, opts.words(text[last:span[0]])...)
+				tokens = append(tokens, text[span[0]:span[1]])
+				last = span[1]
+			}
+		}
+		return append(tokens, opts.words(text[last:])...)
+	}
+}
+
+// linkSpans returns the non-overlapping spans of the URLs and emails of
+// text, trailing punctuation excluded.
+func linkSpans(text string) [][]int {
+	var spans [][]int
+	urls := urlPattern.FindAllStringIndex(text, -1)
+	emails := emailPattern.FindAllStringIndex(text, -1)
+	for len(urls) > 0 || len(emails) > 0 {
+		var span []int
+		if len(emails) == 0 || (len(urls) > 0 && urls[0][0] <= emails[0][0]) {
+			span, urls = urls[0], urls[1:]
+		} else {
+			span, emails = emails[0], emails[1:]
+		}
+		span[1] = span[0] + len(strings.TrimRight(text[span[0]:span[1]], ".,;:!?)]}'"))
+		if len(spans) > 0 && span[0] < spans[len(spans)-1][1] {
+			continue
+		}
+		spans = append(spans, span)
+	}
+	return spans
+}
+
+func (opts UAX29Options) words(text string) []string {
+	var tokens []string
+	segments := uax29Segments(text)
+	for i := 0; i < len(segments); i++ {
+		if !isWordSegment(segments[i]) {
+			continue
+		}
+		// Join hyphenated compounds: word (- word)+
+		j := i
+		for opts.Hyphens != HyphenSplit && j+2 < len(segments) && isHyphen(segments[j+1]) && isWordSegment(segments[j+2]) {
+			j += 2
+		}
+		if j > i {
+			tokens = append(tokens, opts.token(strings.Join(segments[i:j+1], "")))
+			if opts.Hyphens == HyphenBoth {
+				for k := i; k <= j; k += 2 {
+					tokens = append(tokens, opts.token(segments[k]))
+				}
+			}
+			i = j
+			continue
+		}
+		tokens = append(tokens, opts.token(segments[i]))
+	}
+	return tokens
+}
+
+func (opts UAX29Options) token(word string) string {
+	if !opts.Elisions {
+		return word
+	}
+	if i := strings.IndexAny(word, "'’"); i > 0 {
+		prefix := strings.ToLower(word[:i])
+		if len([]rune(prefix)) == 1 || prefix == "qu" {
+			_, size := utf8.DecodeRuneInString(word[i:])
+			return word[i+size:]
+		}
+	}
+	return word
+}
+
+func isWordSegment(segment string) bool {
+	for _, r := range segment {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func isHyphen(segment string) bool {
+	return segment == "-" || segment == "‐" || segment == "‑"
+}
+
+// uax29Tokenizer is the UAX #29 tokenizer with default options.
+var uax29Tokenizer = NewUAX29Tokenizer(UAX29Options{})
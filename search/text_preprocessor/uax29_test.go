@@ -0,0 +1,87 @@
+package text_preprocessor
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestUAX29Segments(t *testing.T) {
+	tests := []struct {
+		rule string
+		text string
+		want []string
+	}{
+		{"WB1", "", nil},
+		{"WB3", "a\r\nb", []string{"a", "\r\n", "b"}},
+		{"WB3a", "a\n\nb", []string{"a", "\n", "\n", "b"}},
+		{"WB3d", "a  b", []string{"a", "  ", "b"}},
+		{"WB4", "naïve", []string{"naïve"}},
+		{"WB5", "Straße", []string{"Straße"}},
+		{"WB6 WB7", "can't a:b e.g.", []string{"can't", " ", "a:b", " ", "e.g", "."}},
+		{"WB7b WB7c", "צה\"ל", []string{"צה\"ל"}},
+		{"WB8", "2024", []string{"2024"}},
+		{"WB9 WB10", "a1b 4x4", []string{"a1b", " ", "4x4"}},
+		{"WB11 WB12", "3.14 1,000,000", []string{"3.14", " ", "1,000,000"}},
+		{"WB11 not MidLetter", "1:2", []string{"1", ":", "2"}},
+		{"WB13", "カタカナ", []string{"カタカナ"}},
+		{"WB13 prolonged sound mark", "タワー", []string{"タワー"}},
+		{"WB13a WB13b", "foo_bar snake_2", []string{"foo_bar", " ", "snake_2"}},
+		{"WB15 WB16", "🇫🇷🇩🇪🇮", []string{"🇫🇷", "🇩🇪", "🇮"}},
+		{"WB999", "hello, world!", []string{"hello", ",", " ", "world", "!"}},
+		{"WB999 Han", "中文", []string{"中", "文"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			if got := uax29Segments(tt.text); !slices.Equal(got, tt.want) {
+				t.Errorf("uax29Segments(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUAX29Tokenizer(t *testing.T) {
+	tests := []struct {
+		name string
+		opts UAX29Options
+		text string
+		want []string
+	}{
+		{"words", UAX29Options{}, "Don't split naïve Straße, 3.14!", []string{"Don't", "split", "naïve", "Straße", "3.14"}},
+		{"url", UAX29Options{}, "see https://example.com/a?b=1. ok", []string{"see", "https://example.com/a?b=1", "ok"}},
+		{"email", UAX29Options{}, "mail bob.smith@example.org now", []string{"mail", "bob.smith@example.org", "now"}},
+		{"split urls", UAX29Options{SplitURLs: true}, "https://example.com/a", []string{"https", "example.com", "a"}},
+		{"hyphens split", UAX29Options{}, "state-of-the-art", []string{"state", "of", "the", "art"}},
+		{"hyphens keep", UAX29Options{Hyphens: HyphenKeep}, "state-of-the-art work", []string{"state-of-the-art", "work"}},
+		{"hyphens both", UAX29Options{Hyphens: HyphenBoth}, "e-mail", []string{"e-mail", "e", "mail"}},
+		{"elisions", UAX29Options{Elisions: true}, "l'homme qu'il aujourd'hui", []string{"homme", "il", "aujourd'hui"}},
+		{"no elisions", UAX29Options{}, "l'homme", []string{"l'homme"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewUAX29Tokenizer(tt.opts)(tt.text); !slices.Equal(got, tt.want) {
+				t.Errorf("tokens of %q = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestUAX29Config checks that NewConfig keeps the punctuation the uax29
+// tokenizer needs to keep numbers and emails whole.
+func TestUAX29Config(t *testing.T) {
+	tests := []struct {
+		tokenizer string
+		want      []string
+	}{
+		{"uax29", []string{"mail", "foo@bar.com", "1,000.50"}},
+		{"word", []string{"mail", "foo", "bar", "1", "000", "50"}},
+	}
+	for _, tt := range tests {
+		config, err := NewConfig(tt.tokenizer, "porter", "english")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := NewTextPreprocessor(config).Process("e-mail foo@bar.com 1,000.50"); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: Process = %q, want %q", tt.tokenizer, got, tt.want)
+		}
+	}
+}